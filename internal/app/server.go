@@ -1,16 +1,130 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"goplayground/internal/biz/knowledge"
 	"goplayground/internal/biz/service"
+	"goplayground/internal/conf"
 )
 
 func Run() {
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		configPath = "configs/config.yaml"
+	}
+	cfg, err := conf.Load(configPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	personas := make([]service.Persona, 0, len(cfg.Personas))
+	for name, p := range cfg.Personas {
+		personas = append(personas, service.Persona{
+			Name:         name,
+			Description:  p.Description,
+			SystemPrompt: p.SystemPrompt,
+		})
+	}
+	service.SetPersonas(personas)
+
+	toolConfigs := make([]service.ToolConfig, 0, len(cfg.Tools))
+	for _, t := range cfg.Tools {
+		params := make([]service.HTTPParam, 0, len(t.Params))
+		for _, p := range t.Params {
+			params = append(params, service.HTTPParam{
+				Name:        p.Name,
+				Type:        p.Type,
+				Description: p.Description,
+				Required:    p.Required,
+				Minimum:     p.Minimum,
+				Maximum:     p.Maximum,
+				Enum:        p.Enum,
+				Default:     p.Default,
+			})
+		}
+		toolConfigs = append(toolConfigs, service.ToolConfig{
+			Name:        t.Name,
+			Type:        t.Type,
+			Description: t.Description,
+			URL:         t.URL,
+			Method:      t.Method,
+			Body:        t.Body,
+			Extract:     t.Extract,
+			Params:      params,
+			Parameters:  t.Parameters,
+			Headers:     t.Headers,
+
+			AllowedHosts:         t.AllowedHosts,
+			AllowedSchemes:       t.AllowedSchemes,
+			AllowPrivateNetworks: t.AllowPrivateNetworks,
+			MaxResponseBytes:     t.MaxResponseBytes,
+
+			DSN:     t.DSN,
+			MaxRows: t.MaxRows,
+
+			ToolPolicy: service.ToolPolicy{
+				Fallback:         t.Fallback,
+				Retries:          t.Retries,
+				RetryBackoff:     t.RetryBackoff,
+				CacheTTL:         t.CacheTTL,
+				CircuitThreshold: t.CircuitThreshold,
+				CircuitCooldown:  t.CircuitCooldown,
+			},
+			RequiresApproval: t.RequiresApproval,
+		})
+	}
+	tools, err := service.LoadToolRegistry(context.Background(), toolConfigs)
+	if err != nil {
+		log.Fatalf("failed to load tools: %v", err)
+	}
+	if cfg.Knowledge.Dir != "" {
+		opts := []knowledge.Option{knowledge.WithChunkSize(cfg.Knowledge.ChunkSize)}
+		if cfg.Knowledge.ChunkOverlap > 0 {
+			opts = append(opts, knowledge.WithChunkOverlap(cfg.Knowledge.ChunkOverlap))
+		}
+		base, err := knowledge.NewBase(context.Background(), knowledge.NewHashEmbedder(cfg.Knowledge.Dimensions), opts...)
+		if err != nil {
+			log.Fatalf("failed to create knowledge base: %v", err)
+		}
+		// 与数据库工具一样，知识库目录不可读时只禁用检索工具
+		files, err := base.AddDir(context.Background(), cfg.Knowledge.Dir)
+		if err != nil {
+			log.Printf("[Knowledge] %s disabled: %v", knowledge.SearchToolName, err)
+		} else {
+			log.Printf("[Knowledge] loaded %d files, %d chunks from %s", files, base.Len(), cfg.Knowledge.Dir)
+			if err := tools.Register(context.Background(), knowledge.NewSearchTool(base)); err != nil {
+				log.Fatalf("failed to register knowledge tool: %v", err)
+			}
+		}
+	}
+	service.SetToolRegistry(tools)
+
+	service.SetTokenBudget(cfg.TokenBudget)
+	service.SetWSConfig(service.WSConfig{
+		AllowedOrigins:  cfg.WebSocket.AllowedOrigins,
+		PingInterval:    cfg.WebSocket.PingInterval,
+		PongTimeout:     cfg.WebSocket.PongTimeout,
+		WriteTimeout:    cfg.WebSocket.WriteTimeout,
+		SendQueue:       cfg.WebSocket.SendQueue,
+		MaxMessageBytes: cfg.WebSocket.MaxMessageBytes,
+	})
+
+	// Persist conversations to disk when a session file is configured,
+	// otherwise they live in the in-memory LRU store.
+	if path := os.Getenv("SESSION_STORE_PATH"); path != "" {
+		store, err := service.NewFileSessionStore(path, service.DefaultSessionTTL)
+		if err != nil {
+			log.Fatalf("failed to open session store: %v", err)
+		}
+		service.SetSessionStore(store)
+	}
+
 	r := gin.New()
 	r.Use(Logger(), gin.Recovery())
 
@@ -19,9 +133,23 @@ func Run() {
 
 	api := r.Group("/ai")
 	{
+		api.GET("/agents", service.HandleListAgents)
+		api.GET("/personas", service.HandleListPersonas)
 		api.GET("/doubao", service.HandleDoubao)
 		api.GET("/ws", service.HandleWebSocket)
 		api.GET("/sse", service.HandleSSE)
+		api.POST("/sse", service.HandleSSE)
+
+		api.POST("/uploads", service.HandleUpload)
+		api.GET("/uploads/:id", service.HandleGetUpload)
+
+		api.GET("/usage", service.HandleUsage)
+		api.GET("/sessions", service.HandleListSessions)
+		api.GET("/sessions/:id", service.HandleGetSession)
+		api.DELETE("/sessions/:id", service.HandleDeleteSession)
+		api.POST("/sessions/:id/reset", service.HandleResetSession)
+		api.POST("/sessions/:id/fork", service.HandleForkSession)
+		api.GET("/sessions/:id/export", service.HandleExportSession)
 	}
 
 	fmt.Println("Server starting on :8080")
@@ -0,0 +1,105 @@
+package knowledge
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// DefaultHashDimensions is the vector size of a HashEmbedder created with zero
+// dimensions.
+const DefaultHashDimensions = 512
+
+// HashEmbedder embeds text locally by feature hashing: words, and single CJK
+// characters and their pairs, are hashed into a fixed number of dimensions. It is
+// deterministic and needs no model, which suits tests and small keyword-like
+// knowledge bases; any other embedding.Embedder can take its place.
+type HashEmbedder struct {
+	Dimensions int
+}
+
+var _ embedding.Embedder = (*HashEmbedder)(nil)
+
+// NewHashEmbedder creates a hashing embedder; dims <= 0 means DefaultHashDimensions.
+func NewHashEmbedder(dims int) *HashEmbedder {
+	if dims <= 0 {
+		dims = DefaultHashDimensions
+	}
+	return &HashEmbedder{Dimensions: dims}
+}
+
+func (e *HashEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float64 {
+	v := make([]float64, e.Dimensions)
+	for _, term := range terms(text) {
+		h := fnv.New64a()
+		h.Write([]byte(term))
+		sum := h.Sum64()
+		sign := 1.0
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		v[sum%uint64(e.Dimensions)] += sign
+	}
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range v {
+			v[i] /= norm
+		}
+	}
+	return v
+}
+
+// terms splits text into lower-cased words, with CJK runs split into characters
+// and character pairs.
+func terms(text string) []string {
+	var (
+		out  []string
+		word []rune
+		prev rune
+	)
+	flush := func() {
+		if len(word) > 0 {
+			out = append(out, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flush()
+			out = append(out, string(r))
+			if prev != 0 {
+				out = append(out, string([]rune{prev, r}))
+			}
+			prev = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prev = 0
+	}
+	flush()
+	return out
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,213 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/cloudwego/eino/components/document/parser"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/indexer"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// DefaultTopK is how many chunks Retrieve returns without retriever.WithTopK.
+	DefaultTopK = 4
+	// embedBatchSize bounds the texts sent to the embedder at once.
+	embedBatchSize = 16
+)
+
+type entry struct {
+	doc    *schema.Document
+	vector []float64
+}
+
+// Index is an in-process vector index searched by cosine similarity. It stores
+// documents as an indexer.Indexer and finds them as a retriever.Retriever.
+type Index struct {
+	embedder embedding.Embedder
+
+	mtx     sync.RWMutex
+	entries []entry
+	ids     map[string]int
+	nextID  int
+}
+
+var (
+	_ indexer.Indexer     = (*Index)(nil)
+	_ retriever.Retriever = (*Index)(nil)
+)
+
+// NewIndex creates an empty index that embeds with embedder.
+func NewIndex(embedder embedding.Embedder) *Index {
+	return &Index{embedder: embedder, ids: make(map[string]int)}
+}
+
+// Store embeds and adds docs, replacing documents with the same id; documents
+// without an id get one.
+func (x *Index) Store(ctx context.Context, docs []*schema.Document, opts ...indexer.Option) ([]string, error) {
+	o := indexer.GetCommonOptions(&indexer.Options{Embedding: x.embedder}, opts...)
+	entries, err := embed(ctx, o.Embedding, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+	return x.add(entries), nil
+}
+
+// ReplaceSource embeds docs and swaps them for the documents parsed from source in
+// one step: searches see either the old or the new documents, and a failed
+// embedding leaves the old ones in place.
+func (x *Index) ReplaceSource(ctx context.Context, source string, docs []*schema.Document) error {
+	entries, err := embed(ctx, x.embedder, docs)
+	if err != nil {
+		return err
+	}
+
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+	x.deleteSource(source)
+	x.add(entries)
+	return nil
+}
+
+// embed computes the vectors of docs in batches.
+func embed(ctx context.Context, embedder embedding.Embedder, docs []*schema.Document) ([]entry, error) {
+	entries := make([]entry, 0, len(docs))
+	for start := 0; start < len(docs); start += embedBatchSize {
+		batch := docs[start:min(start+embedBatchSize, len(docs))]
+		texts := make([]string, len(batch))
+		for i, doc := range batch {
+			texts[i] = doc.Content
+		}
+		vectors, err := embedder.EmbedStrings(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed documents: %v", err)
+		}
+		if len(vectors) != len(batch) {
+			return nil, fmt.Errorf("embedder returned %d vectors for %d documents", len(vectors), len(batch))
+		}
+		for i, doc := range batch {
+			entries = append(entries, entry{doc: doc, vector: vectors[i]})
+		}
+	}
+	return entries, nil
+}
+
+// add inserts entries and returns their ids; x.mtx must be held.
+func (x *Index) add(entries []entry) []string {
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.doc.ID == "" {
+			x.nextID++
+			e.doc.ID = fmt.Sprintf("doc-%d", x.nextID)
+		}
+		if i, ok := x.ids[e.doc.ID]; ok {
+			x.entries[i] = e
+		} else {
+			x.ids[e.doc.ID] = len(x.entries)
+			x.entries = append(x.entries, e)
+		}
+		ids = append(ids, e.doc.ID)
+	}
+	return ids
+}
+
+// Retrieve returns the documents most similar to query, best first, each with its
+// score. It honours retriever.WithTopK, WithScoreThreshold and WithEmbedding.
+func (x *Index) Retrieve(ctx context.Context, query string, opts ...retriever.Option) ([]*schema.Document, error) {
+	topK := DefaultTopK
+	o := retriever.GetCommonOptions(&retriever.Options{TopK: &topK, Embedding: x.embedder}, opts...)
+	vectors, err := o.Embedding.EmbedStrings(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %v", err)
+	}
+	if len(vectors) != 1 {
+		return nil, errors.New("embedder returned no vector for the query")
+	}
+	q := vectors[0]
+
+	type hit struct {
+		doc   *schema.Document
+		score float64
+	}
+	x.mtx.RLock()
+	hits := make([]hit, 0, len(x.entries))
+	for _, e := range x.entries {
+		score := cosine(q, e.vector)
+		if o.ScoreThreshold != nil && score < *o.ScoreThreshold {
+			continue
+		}
+		hits = append(hits, hit{e.doc, score})
+	}
+	x.mtx.RUnlock()
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	if o.TopK != nil && *o.TopK > 0 && len(hits) > *o.TopK {
+		hits = hits[:*o.TopK]
+	}
+	docs := make([]*schema.Document, len(hits))
+	for i, h := range hits {
+		doc := *h.doc
+		doc.MetaData = maps.Clone(h.doc.MetaData)
+		docs[i] = doc.WithScore(h.score)
+	}
+	return docs, nil
+}
+
+// DeleteSource removes the documents parsed from source and returns how many there
+// were.
+func (x *Index) DeleteSource(source string) int {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+	return x.deleteSource(source)
+}
+
+// deleteSource is DeleteSource with x.mtx held.
+func (x *Index) deleteSource(source string) int {
+	kept := x.entries[:0]
+	for _, e := range x.entries {
+		if s, _ := e.doc.MetaData[parser.MetaKeySource].(string); s != source {
+			kept = append(kept, e)
+		}
+	}
+	removed := len(x.entries) - len(kept)
+	clear(x.entries[len(kept):])
+	x.entries = kept
+	x.ids = make(map[string]int, len(kept))
+	for i, e := range kept {
+		x.ids[e.doc.ID] = i
+	}
+	return removed
+}
+
+// Len returns the number of documents in the index.
+func (x *Index) Len() int {
+	x.mtx.RLock()
+	defer x.mtx.RUnlock()
+	return len(x.entries)
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
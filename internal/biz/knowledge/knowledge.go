@@ -0,0 +1,154 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cloudwego/eino/components/document/parser"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Options configure a Base.
+type Options struct {
+	ChunkSize    int
+	ChunkOverlap int
+}
+
+// Option is a functional option for NewBase.
+type Option func(*Options)
+
+// WithChunkSize sets the maximum runes per chunk.
+func WithChunkSize(n int) Option {
+	return func(o *Options) {
+		o.ChunkSize = n
+	}
+}
+
+// WithChunkOverlap sets how many runes a chunk repeats from the previous one.
+func WithChunkOverlap(n int) Option {
+	return func(o *Options) {
+		o.ChunkOverlap = n
+	}
+}
+
+// Base is a knowledge base: it parses and splits documents and keeps their chunks
+// in an Index.
+type Base struct {
+	parser   parser.Parser
+	splitter *Splitter
+	index    *Index
+}
+
+var _ retriever.Retriever = (*Base)(nil)
+
+// NewBase creates an empty knowledge base that embeds with embedder.
+func NewBase(ctx context.Context, embedder embedding.Embedder, opts ...Option) (*Base, error) {
+	o := &Options{ChunkOverlap: -1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	p, err := NewParser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parser: %v", err)
+	}
+	return &Base{
+		parser:   p,
+		splitter: NewSplitter(o.ChunkSize, o.ChunkOverlap),
+		index:    NewIndex(embedder),
+	}, nil
+}
+
+// Add parses the content of r as the document source, whose extension selects the
+// parser, and indexes its chunks in place of any earlier version, which stays
+// searchable until the new chunks are embedded. It returns the number of chunks.
+func (b *Base) Add(ctx context.Context, source string, r io.Reader) (int, error) {
+	docs, err := b.parser.Parse(ctx, r, parser.WithURI(source))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %v", source, err)
+	}
+	for _, doc := range docs {
+		doc.ID = source
+		if _, ok := doc.MetaData[MetaKeyTitle]; !ok {
+			doc.MetaData[MetaKeyTitle] = strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+		}
+	}
+	chunks, err := b.splitter.Transform(ctx, docs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to split %s: %v", source, err)
+	}
+	// 先嵌入再替换，嵌入失败时保留旧版本
+	if err := b.index.ReplaceSource(ctx, source, chunks); err != nil {
+		return 0, fmt.Errorf("failed to index %s: %v", source, err)
+	}
+	return len(chunks), nil
+}
+
+// AddFile adds the file at path.
+func (b *Base) AddFile(ctx context.Context, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	return b.Add(ctx, path, f)
+}
+
+// AddDir adds every file with one of the Extensions under dir and returns the number
+// of files added. A file or subdirectory that cannot be read or parsed is logged and
+// skipped; only an unreadable dir is an error.
+func (b *Base) AddDir(ctx context.Context, dir string) (int, error) {
+	files, skipped := 0, 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			log.Printf("[Knowledge] skipping %s: %v", path, err)
+			skipped++
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !slices.Contains(Extensions, strings.ToLower(filepath.Ext(path))) {
+			return nil
+		}
+		n, err := b.AddFile(ctx, path)
+		if err != nil {
+			log.Printf("[Knowledge] skipping %s: %v", path, err)
+			skipped++
+			return nil
+		}
+		log.Printf("[Knowledge] indexed %s: %d chunks", path, n)
+		files++
+		return nil
+	})
+	if skipped > 0 {
+		log.Printf("[Knowledge] skipped %d entries under %s", skipped, dir)
+	}
+	return files, err
+}
+
+// Remove drops the chunks of source.
+func (b *Base) Remove(source string) {
+	b.index.DeleteSource(source)
+}
+
+// Len returns the number of chunks in the base.
+func (b *Base) Len() int {
+	return b.index.Len()
+}
+
+// Retrieve returns the chunks most similar to query.
+func (b *Base) Retrieve(ctx context.Context, query string, opts ...retriever.Option) ([]*schema.Document, error) {
+	return b.index.Retrieve(ctx, query, opts...)
+}
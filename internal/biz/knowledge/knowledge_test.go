@@ -0,0 +1,201 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/document/parser"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/retriever"
+
+	"goplayground/internal/biz/service"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	e := NewHashEmbedder(64)
+	v, _ := e.EmbedStrings(context.Background(), []string{"退货政策 Refund", "退货政策 refund", "天气"})
+	if len(v[0]) != 64 {
+		t.Fatalf("expect %d dimensions, but got %d", 64, len(v[0]))
+	}
+	if s := cosine(v[0], v[1]); s < 0.999 {
+		t.Errorf("expect identical terms to embed the same, but got similarity %f", s)
+	}
+	if cosine(v[0], v[2]) >= cosine(v[0], v[1]) {
+		t.Errorf("expect unrelated text to be less similar")
+	}
+}
+
+func newTestBase(t *testing.T) (*Base, string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"refund.md":    "---\nowner: 客服\n---\n# 退货政策\n\n商品签收后七天内可以无理由退货，运费由买家承担。\n\n质量问题退货的运费由商家承担。",
+		"shipping.txt": "发货时间：工作日下单当天发货，周末下单顺延到周一。\n\n偏远地区配送需要三到五天。",
+		"manual.pdf":   string(buildPDF(true, "BT (Reset the router by holding the button for ten seconds.) Tj ET")),
+		"ignored.bin":  "binary",
+		"broken.pdf":   "not a pdf", // 解析失败的文件被跳过
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base, err := NewBase(context.Background(), NewHashEmbedder(0), WithChunkSize(60), WithChunkOverlap(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := base.AddDir(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expect %d files indexed, but got %d", 3, n)
+	}
+	return base, dir
+}
+
+func TestBase_Retrieve(t *testing.T) {
+	ctx := context.Background()
+	base, dir := newTestBase(t)
+
+	cases := []struct {
+		query  string
+		source string
+	}{
+		{"退货的运费谁承担", "refund.md"},
+		{"周末下单什么时候发货", "shipping.txt"},
+		{"how to reset the router", "manual.pdf"},
+	}
+	for _, c := range cases {
+		docs, err := base.Retrieve(ctx, c.query, retriever.WithTopK(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(docs) != 1 {
+			t.Fatalf("%s: expect %d result, but got %d", c.query, 1, len(docs))
+		}
+		if source := docs[0].MetaData[parser.MetaKeySource]; source != filepath.Join(dir, c.source) {
+			t.Errorf("%s: expect %s, but got %v", c.query, c.source, source)
+		}
+		if docs[0].Score() <= 0 {
+			t.Errorf("%s: expect a positive score, but got %f", c.query, docs[0].Score())
+		}
+	}
+	docs, _ := base.Retrieve(ctx, "退货", retriever.WithTopK(1))
+	if docs[0].MetaData[MetaKeyTitle] != "退货政策" || strings.Contains(docs[0].Content, "owner") {
+		t.Errorf("expect the markdown title and no front matter, but got %v %q", docs[0].MetaData, docs[0].Content)
+	}
+
+	// 重新添加同一文件会替换旧的片段
+	before := base.Len()
+	refund := filepath.Join(dir, "refund.md")
+	if _, err := base.Add(ctx, refund, strings.NewReader("# 退货政策\n\n暂停退货。")); err != nil {
+		t.Fatal(err)
+	}
+	docs, _ = base.Retrieve(ctx, "退货", retriever.WithTopK(10), retriever.WithScoreThreshold(0.01))
+	for _, doc := range docs {
+		if doc.MetaData[parser.MetaKeySource] == refund && !strings.Contains(doc.Content, "暂停退货") {
+			t.Errorf("expect the old chunks replaced, but got %q", doc.Content)
+		}
+	}
+	if base.Len() != before {
+		t.Errorf("expect %d chunks after replacing the document, but got %d", before, base.Len())
+	}
+	base.Remove(refund)
+	if docs, _ := base.Retrieve(ctx, "暂停退货", retriever.WithTopK(1)); len(docs) > 0 && docs[0].MetaData[parser.MetaKeySource] == refund {
+		t.Errorf("expect the removed document not to be found")
+	}
+}
+
+// flakyEmbedder fails while fail is set.
+type flakyEmbedder struct {
+	embedding.Embedder
+	fail bool
+}
+
+func (e *flakyEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	if e.fail {
+		return nil, errors.New("embedder unavailable")
+	}
+	return e.Embedder.EmbedStrings(ctx, texts, opts...)
+}
+
+func TestBase_Add(t *testing.T) {
+	ctx := context.Background()
+	embedder := &flakyEmbedder{Embedder: NewHashEmbedder(0)}
+	base, err := NewBase(ctx, embedder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 扩展名不区分大小写
+	pdf := buildPDF(true, "BT (Reset the router by holding the button for ten seconds.) Tj ET")
+	if _, err := base.Add(ctx, "Guide.PDF", strings.NewReader(string(pdf))); err != nil {
+		t.Fatal(err)
+	}
+	docs, _ := base.Retrieve(ctx, "reset the router", retriever.WithTopK(1))
+	if len(docs) != 1 || !strings.HasPrefix(docs[0].Content, "Reset the router") {
+		t.Fatalf("expect Guide.PDF parsed as a PDF, but got %v", docs)
+	}
+	if docs[0].MetaData[parser.MetaKeySource] != "Guide.PDF" || docs[0].MetaData[MetaKeyTitle] != "Guide" {
+		t.Errorf("expect the original source and title, but got %v", docs[0].MetaData)
+	}
+
+	// 目录本身不可读时才返回错误
+	if _, err := base.AddDir(ctx, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expect an error for a missing directory")
+	}
+
+	// 嵌入失败时保留旧的片段
+	embedder.fail = true
+	if _, err := base.Add(ctx, "Guide.PDF", strings.NewReader("Unplug the router.")); err == nil {
+		t.Fatalf("expect an embedding error")
+	}
+	embedder.fail = false
+	docs, _ = base.Retrieve(ctx, "router", retriever.WithTopK(10))
+	if len(docs) != 1 || !strings.HasPrefix(docs[0].Content, "Reset the router") {
+		t.Errorf("expect the old chunks kept, but got %v", docs)
+	}
+}
+
+func TestSearchTool(t *testing.T) {
+	ctx := context.Background()
+	base, _ := newTestBase(t)
+	search := NewSearchTool(base)
+
+	out, err := search.InvokableRun(ctx, `{"query":"退货运费","top_k":2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp SearchResponse
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) == 0 || resp.Results[0].Title != "退货政策" || len(resp.Results) > 2 {
+		t.Errorf("unexpected results %+v", resp.Results)
+	}
+
+	// 通过 WithTools 交给 agent 使用
+	agent, err := service.NewAgent(service.MockAgent, "knowledge-test", ctx,
+		service.WithTools(search),
+		service.WithMockScript(&service.MockScript{Replies: []service.MockReply{{
+			ToolCalls: []service.MockToolCall{{Name: SearchToolName, Arguments: `{"query":"周末发货"}`}},
+		}}}),
+		service.WithSessionStore(service.NewMemorySessionStore(0, 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := agent.Chat(ctx, "周末下单什么时候发？")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(answer, "周一") {
+		t.Errorf("expect the tool result in the answer, but got %q", answer)
+	}
+}
@@ -0,0 +1,98 @@
+// Package knowledge gives agents a local knowledge base: documents are parsed,
+// split into chunks, embedded and kept in an in-process vector index that the
+// search_knowledge tool queries.
+package knowledge
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwego/eino/components/document/parser"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Metadata keys set on documents and chunks, next to parser.MetaKeySource.
+const (
+	MetaKeyTitle = "title"
+	MetaKeyChunk = "chunk"
+)
+
+// Extensions lists the file extensions NewParser handles.
+var Extensions = []string{".md", ".markdown", ".txt", ".pdf"}
+
+// NewParser returns a parser that picks Markdown, text or PDF parsing by the
+// extension of the parser.WithURI option, in any case, and parses other files as
+// text.
+func NewParser(ctx context.Context) (parser.Parser, error) {
+	return extParser{
+		".md":       MarkdownParser{},
+		".markdown": MarkdownParser{},
+		".txt":      parser.TextParser{},
+		".pdf":      PDFParser{},
+	}, nil
+}
+
+// extParser works like parser.ExtParser, whose lookup is case-sensitive, but also
+// matches upper-case extensions such as Guide.PDF.
+type extParser map[string]parser.Parser
+
+func (p extParser) Parse(ctx context.Context, r io.Reader, opts ...parser.Option) ([]*schema.Document, error) {
+	o := parser.GetCommonOptions(&parser.Options{}, opts...)
+	if sub, ok := p[strings.ToLower(filepath.Ext(o.URI))]; ok {
+		return sub.Parse(ctx, r, opts...)
+	}
+	return parser.TextParser{}.Parse(ctx, r, opts...)
+}
+
+// MarkdownParser parses a Markdown file into one document, dropping YAML front
+// matter and taking the title from the first top-level heading.
+type MarkdownParser struct{}
+
+func (MarkdownParser) Parse(ctx context.Context, r io.Reader, opts ...parser.Option) ([]*schema.Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+	if rest, ok := strings.CutPrefix(content, "---\n"); ok {
+		if _, body, ok := strings.Cut(rest, "\n---\n"); ok {
+			content = body
+		}
+	}
+
+	doc := newDocument(content, opts)
+	for _, line := range strings.Split(content, "\n") {
+		if title, ok := strings.CutPrefix(line, "# "); ok {
+			doc.MetaData[MetaKeyTitle] = strings.TrimSpace(title)
+			break
+		}
+	}
+	return []*schema.Document{doc}, nil
+}
+
+// PDFParser parses the text of a PDF into one document; see pdfText for what it
+// can extract.
+type PDFParser struct{}
+
+func (PDFParser) Parse(ctx context.Context, r io.Reader, opts ...parser.Option) ([]*schema.Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text, err := pdfText(data)
+	if err != nil {
+		return nil, err
+	}
+	return []*schema.Document{newDocument(text, opts)}, nil
+}
+
+func newDocument(content string, opts []parser.Option) *schema.Document {
+	o := parser.GetCommonOptions(&parser.Options{}, opts...)
+	meta := map[string]any{parser.MetaKeySource: o.URI}
+	for k, v := range o.ExtraMeta {
+		meta[k] = v
+	}
+	return &schema.Document{Content: content, MetaData: meta}
+}
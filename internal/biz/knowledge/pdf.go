@@ -0,0 +1,258 @@
+package knowledge
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFStreamBytes bounds a decompressed content stream.
+const maxPDFStreamBytes = 16 << 20
+
+// pdfText extracts the text of a PDF without a full PDF implementation: it reads the
+// text operators (Tj, TJ, ' and ") of every uncompressed or FlateDecode stream.
+// Strings are decoded as UTF-16 when they carry a byte order mark and as Latin-1
+// otherwise, so text in fonts with custom encodings, as most CJK PDFs use, comes out
+// garbled; convert such files to text before adding them.
+func pdfText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("not a PDF file")
+	}
+	var out strings.Builder
+	rest := data
+	for {
+		i := bytes.Index(rest, []byte("stream"))
+		if i < 0 {
+			break
+		}
+		dict := rest[:i]
+		if j := bytes.LastIndex(dict, []byte("<<")); j >= 0 {
+			dict = dict[j:]
+		}
+		body := rest[i+len("stream"):]
+		body = bytes.TrimPrefix(body, []byte("\r"))
+		body = bytes.TrimPrefix(body, []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		rest = body[end+len("endstream"):]
+		body = body[:end]
+
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			zr, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				continue // 图片等其它压缩流
+			}
+			body, err = io.ReadAll(io.LimitReader(zr, maxPDFStreamBytes))
+			zr.Close()
+			if err != nil && len(body) == 0 {
+				continue
+			}
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+		pdfContentText(body, &out)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// pdfContentText appends the text shown in a content stream to out.
+func pdfContentText(content []byte, out *strings.Builder) {
+	var (
+		inText, inArray bool
+		operands, array []string
+	)
+	lineStart := out.Len() == 0
+	write := func(s string) {
+		if s != "" {
+			out.WriteString(s)
+			lineStart = strings.HasSuffix(s, "\n")
+		}
+	}
+	newline := func() {
+		if !lineStart {
+			write("\n")
+		}
+	}
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, n := pdfLiteral(content[i:])
+			i += n
+			if inArray {
+				array = append(array, s)
+			} else {
+				operands = append(operands, s)
+			}
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return
+			}
+			s := pdfHex(content[i+1 : i+end])
+			i += end + 1
+			if inArray {
+				array = append(array, s)
+			} else {
+				operands = append(operands, s)
+			}
+		case c == '[':
+			inArray, array = true, nil
+			i++
+		case c == ']':
+			inArray = false
+			operands = append(operands, strings.Join(array, ""))
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isPDFSpace(c):
+			i++
+		default:
+			start := i
+			for i < len(content) && !isPDFSpace(content[i]) && !strings.ContainsRune("()<>[]/%", rune(content[i])) {
+				i++
+			}
+			if i == start {
+				i++ // 名字等分隔符
+				continue
+			}
+			token := string(content[start:i])
+			if inArray {
+				// TJ 中较大的负间距（-100 及以上）通常是单词间的空格
+				if token[0] == '-' && len(token) >= 4 {
+					array = append(array, " ")
+				}
+				continue
+			}
+			switch token {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				newline()
+			case "Tj", "TJ":
+				if inText && len(operands) > 0 {
+					write(operands[len(operands)-1])
+				}
+			case "'", "\"":
+				if inText && len(operands) > 0 {
+					newline()
+					write(operands[len(operands)-1])
+				}
+			case "Td", "TD", "T*", "Tm":
+				if inText {
+					newline()
+				}
+			}
+			if !isPDFNumber(token) {
+				operands = operands[:0]
+			}
+		}
+	}
+}
+
+// pdfLiteral decodes the literal string at the start of b and returns it with the
+// number of bytes consumed.
+func pdfLiteral(b []byte) (string, int) {
+	var s []byte
+	depth := 0
+	i := 0
+	for ; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == '\\' && i+1 < len(b):
+			i++
+			switch e := b[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '\n', '\r':
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					for k := 0; k < 3 && i < len(b) && b[i] >= '0' && b[i] <= '7'; k++ {
+						v = v*8 + int(b[i]-'0')
+						i++
+					}
+					i--
+					s = append(s, byte(v))
+				} else {
+					s = append(s, e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				s = append(s, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return pdfString(s), i + 1
+			}
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+	return pdfString(s), i
+}
+
+func pdfHex(b []byte) string {
+	h := bytes.Map(func(r rune) rune {
+		if isPDFSpace(byte(r)) {
+			return -1
+		}
+		return r
+	}, b)
+	if len(h)%2 == 1 {
+		h = append(h, '0')
+	}
+	s, err := hex.DecodeString(string(h))
+	if err != nil {
+		return ""
+	}
+	return pdfString(s)
+}
+
+// pdfString decodes UTF-16BE strings with a byte order mark and treats the rest
+// as Latin-1.
+func pdfString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		u := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	r := make([]rune, len(s))
+	for i, c := range s {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFNumber(token string) bool {
+	for i, c := range token {
+		if (c < '0' || c > '9') && c != '.' && !(i == 0 && (c == '-' || c == '+')) {
+			return false
+		}
+	}
+	return true
+}
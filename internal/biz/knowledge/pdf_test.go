@@ -0,0 +1,49 @@
+package knowledge
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+// buildPDF wraps content streams into a minimal PDF; compressed streams are
+// FlateDecode encoded.
+func buildPDF(compressed bool, streams ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, s := range streams {
+		data := []byte(s)
+		filter := ""
+		if compressed {
+			var z bytes.Buffer
+			w := zlib.NewWriter(&z)
+			w.Write(data)
+			w.Close()
+			data, filter = z.Bytes(), " /Filter /FlateDecode"
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Length %d%s >>\nstream\n%s\nendstream\nendobj\n", i+1, len(data), filter, data)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestPDFText(t *testing.T) {
+	content := "BT /F1 12 Tf 72 712 Td (Hello, PDF\\051) Tj 0 -14 Td [(Wor) 20 (ld) -250 (again)] TJ ET\n" +
+		"BT (Second \\(nested\\) line) Tj T* <FEFF4F60597D> Tj ET"
+	expect := "Hello, PDF)\nWorld again\nSecond (nested) line\n你好"
+
+	for _, compressed := range []bool{false, true} {
+		text, err := pdfText(buildPDF(compressed, content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != expect {
+			t.Errorf("compressed=%v: expect %q, but got %q", compressed, expect, text)
+		}
+	}
+
+	if _, err := pdfText([]byte("not a pdf")); err == nil {
+		t.Errorf("expect an error for a non-PDF file")
+	}
+}
@@ -0,0 +1,109 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/cloudwego/eino/components/document"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	DefaultChunkSize    = 500
+	DefaultChunkOverlap = 50
+)
+
+// Splitter splits documents into chunks of at most Size runes along paragraph and
+// sentence boundaries, each repeating the last Overlap runes of the previous one so
+// that a fact cut in two is still found whole in one of them.
+type Splitter struct {
+	Size    int
+	Overlap int
+}
+
+var _ document.Transformer = (*Splitter)(nil)
+
+// NewSplitter creates a splitter; size <= 0 means DefaultChunkSize and overlap < 0
+// means DefaultChunkOverlap.
+func NewSplitter(size, overlap int) *Splitter {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 {
+		overlap = DefaultChunkOverlap
+	}
+	return &Splitter{Size: size, Overlap: min(overlap, size/2)}
+}
+
+// Transform returns the chunks of src. A chunk copies its document's metadata, adds
+// MetaKeyChunk and gets the id "<document id>#<n>".
+func (s *Splitter) Transform(ctx context.Context, src []*schema.Document, opts ...document.TransformerOption) ([]*schema.Document, error) {
+	var chunks []*schema.Document
+	for _, doc := range src {
+		for i, text := range s.split(doc.Content) {
+			meta := maps.Clone(doc.MetaData)
+			if meta == nil {
+				meta = make(map[string]any)
+			}
+			meta[MetaKeyChunk] = i
+			chunks = append(chunks, &schema.Document{
+				ID:       fmt.Sprintf("%s#%d", doc.ID, i),
+				Content:  text,
+				MetaData: meta,
+			})
+		}
+	}
+	return chunks, nil
+}
+
+func (s *Splitter) split(content string) []string {
+	var (
+		chunks []string
+		cur    []rune
+	)
+	for _, para := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		// a piece always fits after the overlap, so a new chunk starts with it
+		for _, piece := range s.pieces([]rune(para)) {
+			if len(cur) > 0 && len(cur)+2+len(piece) > s.Size {
+				chunks = append(chunks, strings.TrimSpace(string(cur)))
+				cur = append([]rune(nil), cur[max(len(cur)-s.Overlap, 0):]...)
+			}
+			if len(cur) > 0 {
+				cur = append(cur, '\n', '\n')
+			}
+			cur = append(cur, piece...)
+		}
+	}
+	if len(cur) > 0 {
+		chunks = append(chunks, strings.TrimSpace(string(cur)))
+	}
+	return chunks
+}
+
+// pieces cuts a paragraph longer than a chunk at sentence ends, or anywhere when a
+// sentence is too long itself.
+func (s *Splitter) pieces(para []rune) [][]rune {
+	limit := max(s.Size-s.Overlap-2, 1)
+	var out [][]rune
+	for len(para) > limit {
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if strings.ContainsRune("。！？；.!?;\n", para[i-1]) {
+				cut = i
+				break
+			}
+		}
+		out = append(out, para[:cut])
+		para = []rune(strings.TrimLeft(string(para[cut:]), " "))
+	}
+	if len(para) > 0 {
+		out = append(out, para)
+	}
+	return out
+}
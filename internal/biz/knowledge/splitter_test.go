@@ -0,0 +1,48 @@
+package knowledge
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestSplitter(t *testing.T) {
+	s := NewSplitter(40, 10)
+	content := "第一段很短。\n\n" +
+		strings.Repeat("这是一个很长的句子。", 8) + "\n\n" +
+		"最后一段。"
+	chunks, err := s.Transform(context.Background(), []*schema.Document{{
+		ID:       "doc.md",
+		Content:  content,
+		MetaData: map[string]any{MetaKeyTitle: "示例"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("expect the document split into several chunks, but got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if n := utf8.RuneCountInString(c.Content); n > 40 {
+			t.Errorf("chunk %d: expect at most %d runes, but got %d", i, 40, n)
+		}
+		if c.ID != "doc.md#"+string(rune('0'+i)) || c.MetaData[MetaKeyChunk] != i || c.MetaData[MetaKeyTitle] != "示例" {
+			t.Errorf("chunk %d: unexpected id or metadata %s %v", i, c.ID, c.MetaData)
+		}
+	}
+	if !strings.HasPrefix(chunks[0].Content, "第一段很短。") || !strings.HasSuffix(chunks[len(chunks)-1].Content, "最后一段。") {
+		t.Errorf("expect the chunks to keep the order of the text, but got %q ... %q", chunks[0].Content, chunks[len(chunks)-1].Content)
+	}
+	// 相邻片段有重叠
+	prev := []rune(chunks[1].Content)
+	if !strings.HasPrefix(chunks[2].Content, string(prev[len(prev)-10:])) {
+		t.Errorf("expect chunk 2 to start with the end of chunk 1, but got %q after %q", chunks[2].Content, chunks[1].Content)
+	}
+
+	if chunks, _ := s.Transform(context.Background(), []*schema.Document{{ID: "empty", Content: " \n\n "}}); len(chunks) != 0 {
+		t.Errorf("expect no chunks for an empty document, but got %d", len(chunks))
+	}
+}
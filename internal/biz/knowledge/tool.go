@@ -0,0 +1,80 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/cloudwego/eino/components/document/parser"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// SearchToolName is the name of the tool created by NewSearchTool.
+	SearchToolName = "search_knowledge"
+	// maxSearchTopK bounds the results the model may ask for.
+	maxSearchTopK = 10
+)
+
+// SearchRequest is the input schema of the search_knowledge tool.
+type SearchRequest struct {
+	Query string `json:"query" jsonschema:"description=要在知识库中检索的问题或关键词"`
+	TopK  int    `json:"top_k,omitempty" jsonschema:"description=可选：返回的片段数量，默认 4，最多 10"`
+}
+
+// SearchResult is one chunk found by the search_knowledge tool.
+type SearchResult struct {
+	Source  string  `json:"source"`
+	Title   string  `json:"title,omitempty"`
+	Score   float64 `json:"score"`
+	Content string  `json:"content"`
+}
+
+// SearchResponse is the output schema of the search_knowledge tool.
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+}
+
+// NewSearchTool creates the search_knowledge tool over r, usually a Base; pass it
+// to an agent with service.WithTools or register it in the tool registry.
+func NewSearchTool(r retriever.Retriever) tool.InvokableTool {
+	params, _ := utils.GoStruct2ParamsOneOf[SearchRequest]()
+	return utils.NewTool[SearchRequest, SearchResponse](
+		&schema.ToolInfo{
+			Name:        SearchToolName,
+			Desc:        "在本地知识库中检索与问题相关的文档片段。回答涉及内部文档、产品说明等知识时先调用它，并在回答中注明来源。",
+			ParamsOneOf: params,
+		},
+		func(ctx context.Context, input SearchRequest) (SearchResponse, error) {
+			if input.Query == "" {
+				return SearchResponse{}, fmt.Errorf("query is required")
+			}
+			topK := DefaultTopK
+			if input.TopK > 0 {
+				topK = min(input.TopK, maxSearchTopK)
+			}
+			docs, err := r.Retrieve(ctx, input.Query, retriever.WithTopK(topK))
+			if err != nil {
+				return SearchResponse{}, err
+			}
+			resp := SearchResponse{Results: make([]SearchResult, 0, len(docs))}
+			for _, doc := range docs {
+				if doc.Score() <= 0 {
+					continue // 没有任何共同的词，与问题无关
+				}
+				source, _ := doc.MetaData[parser.MetaKeySource].(string)
+				title, _ := doc.MetaData[MetaKeyTitle].(string)
+				resp.Results = append(resp.Results, SearchResult{
+					Source:  source,
+					Title:   title,
+					Score:   math.Round(doc.Score()*1000) / 1000,
+					Content: doc.Content,
+				})
+			}
+			return resp, nil
+		},
+	)
+}
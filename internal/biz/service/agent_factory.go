@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/cloudwego/eino/components/tool"
@@ -12,13 +14,47 @@ type AgentType string
 
 const (
 	DouBaoAgent AgentType = "doubao"
+	MockAgent   AgentType = "mock"
+	OpenAIAgent AgentType = "openai"
 )
 
 // AgentOptions holds the configuration for creating an agent.
 type AgentOptions struct {
 	ModelID string
+	BaseURL string
 	Timeout time.Duration
 	Tools   []tool.InvokableTool
+	Store   SessionStore
+	Mock    *MockScript
+
+	// AgentType is the type the agent was created as, set by NewAgent.
+	AgentType AgentType
+	// TokenBudget caps the tokens of the agent's session; zero or less is unlimited.
+	// NewAgent replaces zero with the default set with SetTokenBudget.
+	TokenBudget int
+	// SessionWait bounds how long a turn waits for other turns of its session.
+	SessionWait time.Duration
+
+	// MaxIterations caps the model calls of one turn's tool loop, ToolTimeout bounds
+	// each tool invocation. Zero values fall back to the ToolLoop defaults.
+	MaxIterations int
+	ToolTimeout   time.Duration
+
+	OnEvent EventHandler
+	History HistoryStrategy
+
+	// Approver decides on calls to tools marked with RequireApproval, waiting at
+	// most ApprovalTimeout.
+	Approver        Approver
+	ApprovalTimeout time.Duration
+
+	// SystemPrompt, PromptTemplate and Persona are alternative ways to set the
+	// system message, in decreasing precedence.
+	SystemPrompt   string
+	PromptTemplate string
+	PromptVars     map[string]any
+	Persona        string
+	Locale         string
 }
 
 // Option is a functional option for configuring an Agent.
@@ -30,6 +66,14 @@ func WithModelID(modelID string) Option {
 	}
 }
 
+// WithBaseURL points backends with a configurable endpoint (e.g. OpenAI-compatible
+// servers) at baseURL.
+func WithBaseURL(baseURL string) Option {
+	return func(o *AgentOptions) {
+		o.BaseURL = baseURL
+	}
+}
+
 func WithTimeout(timeout time.Duration) Option {
 	return func(o *AgentOptions) {
 		o.Timeout = timeout
@@ -42,6 +86,73 @@ func WithTools(tools ...tool.InvokableTool) Option {
 	}
 }
 
+func WithMaxIterations(n int) Option {
+	return func(o *AgentOptions) {
+		o.MaxIterations = n
+	}
+}
+
+func WithToolTimeout(timeout time.Duration) Option {
+	return func(o *AgentOptions) {
+		o.ToolTimeout = timeout
+	}
+}
+
+// WithSessionStore overrides the store the agent loads and saves its history in.
+func WithSessionStore(store SessionStore) Option {
+	return func(o *AgentOptions) {
+		o.Store = store
+	}
+}
+
+// AgentFactory describes how to build agents of one type.
+type AgentFactory struct {
+	New          func(sessionId string, ctx context.Context, opts *AgentOptions) (Agent, error)
+	DefaultModel func() string
+	Description  string
+}
+
+// AgentInfo is the public description of a registered agent type.
+type AgentInfo struct {
+	Type         AgentType `json:"type"`
+	DefaultModel string    `json:"defaultModel"`
+	Description  string    `json:"description"`
+}
+
+var (
+	agentsMtx sync.RWMutex
+	agents    = make(map[AgentType]AgentFactory)
+)
+
+// RegisterAgent makes an agent type available to NewAgent. Backends call it from init;
+// registering the same type twice replaces the earlier factory.
+func RegisterAgent(agentType AgentType, factory AgentFactory) {
+	if factory.New == nil {
+		panic(fmt.Sprintf("agent %s registered without constructor", agentType))
+	}
+	agentsMtx.Lock()
+	defer agentsMtx.Unlock()
+	agents[agentType] = factory
+}
+
+// ListAgents returns the registered agent types sorted by name.
+func ListAgents() []AgentInfo {
+	agentsMtx.RLock()
+	defer agentsMtx.RUnlock()
+	infos := make([]AgentInfo, 0, len(agents))
+	for t, f := range agents {
+		info := AgentInfo{Type: t, Description: f.Description}
+		if f.DefaultModel != nil {
+			info.DefaultModel = f.DefaultModel()
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Type < infos[j].Type
+	})
+	return infos
+}
+
 func NewAgent(agentType AgentType, sessionId string, ctx context.Context, opts ...Option) (Agent, error) {
 	options := &AgentOptions{
 		Timeout: 30 * time.Second, // default timeout
@@ -49,11 +160,16 @@ func NewAgent(agentType AgentType, sessionId string, ctx context.Context, opts .
 	for _, opt := range opts {
 		opt(options)
 	}
+	if options.TokenBudget == 0 {
+		options.TokenBudget = tokenBudget()
+	}
+	options.AgentType = agentType
 
-	switch agentType {
-	case DouBaoAgent:
-		return NewDouBao(sessionId, ctx, options)
-	default:
+	agentsMtx.RLock()
+	factory, ok := agents[agentType]
+	agentsMtx.RUnlock()
+	if !ok {
 		return nil, fmt.Errorf("unknown agent type: %s", agentType)
 	}
+	return factory.New(sessionId, ctx, options)
 }
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRegisterAgent(t *testing.T) {
+	const custom AgentType = "custom-test"
+	RegisterAgent(custom, AgentFactory{
+		New: func(sessionId string, ctx context.Context, opts *AgentOptions) (Agent, error) {
+			return NewMock(sessionId, ctx, opts)
+		},
+		DefaultModel: func() string { return "custom-model" },
+	})
+	defer func() {
+		agentsMtx.Lock()
+		delete(agents, custom)
+		agentsMtx.Unlock()
+	}()
+
+	var found bool
+	for _, info := range ListAgents() {
+		if info.Type == custom {
+			found = true
+			if info.DefaultModel != "custom-model" {
+				t.Errorf("expect %s, but got %s", "custom-model", info.DefaultModel)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expect %s to be listed", custom)
+	}
+
+	if _, err := NewAgent(custom, "registry-test", context.Background(), WithSessionStore(NewMemorySessionStore(0, 0))); err != nil {
+		t.Errorf("expect agent, but got %v", err)
+	}
+	if _, err := NewAgent("unknown", "registry-test", context.Background()); err == nil {
+		t.Errorf("expect error for unknown agent type")
+	}
+}
@@ -1,19 +1,103 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 
-	"github.com/cloudwego/eino/schema"
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// wsEvent wraps an AgentEvent in the WebSocket message envelope.
+type wsEvent struct {
+	Type string `json:"type"`
+	AgentEvent
+}
+
+// wsRequest is a message from a WebSocket client: a chat turn, the answer to an
+// approval_request when Type is "approval", or "cancel" to abort the turn in flight.
+type wsRequest struct {
+	SessionID string `json:"sessionId"`
+	Content   string `json:"content"`
+	Type      string `json:"type"`      // "chat", "approval" or "cancel"
+	AgentType string `json:"agentType"` // "doubao", "mock", etc.
+	Persona   string `json:"persona"`
+	// Tools names the registered tools offered for this message only; nil means all
+	// of them and an empty list none.
+	Tools       []string     `json:"tools"`
+	Attachments []Attachment `json:"attachments"`
+
+	ToolCallID string `json:"toolCallId"`
+	Approved   bool   `json:"approved"`
+	Reason     string `json:"reason"`
+}
+
+func HandleListAgents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"agents": ListAgents()})
+}
+
+func HandleListPersonas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"personas": ListPersonas()})
+}
+
+// addUsage adds the usage of agent's last turn to a response, if the agent reports
+// it.
+func addUsage(h gin.H, agent Agent) gin.H {
+	if r, ok := agent.(UsageReporter); ok {
+		h["usage"] = r.LastUsage()
+	}
+	return h
+}
+
+// errorStatus maps the error of a turn to an HTTP status.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrSessionBusy):
+		return http.StatusConflict
+	case errors.Is(err, ErrTokenBudgetExceeded):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// queryTools reads the comma-separated tools query parameter; nil when it is absent.
+func queryTools(c *gin.Context) []string {
+	v, ok := c.GetQuery("tools")
+	if !ok {
+		return nil
+	}
+	tools := make([]string, 0)
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			tools = append(tools, name)
+		}
+	}
+	return tools
+}
+
+// callTools turns the tool names of a request into call options; nil names keep
+// the agent's own tools.
+func callTools(names []string) ([]CallOption, error) {
+	if names == nil {
+		return nil, nil
+	}
+	tools, err := DefaultToolRegistry().Select(names)
+	if err != nil {
+		return nil, err
+	}
+	return []CallOption{WithCallTools(tools...)}, nil
+}
+
+// requestLocale returns the first language tag of the Accept-Language header.
+func requestLocale(c *gin.Context) string {
+	lang := c.GetHeader("Accept-Language")
+	if i := strings.IndexAny(lang, ",;"); i >= 0 {
+		lang = lang[:i]
+	}
+	return strings.TrimSpace(lang)
 }
 
 func HandleDoubao(c *gin.Context) {
@@ -22,25 +106,33 @@ func HandleDoubao(c *gin.Context) {
 	if sessionId == "" {
 		sessionId = "default"
 	}
+	agentType := c.Query("agentType")
+	if agentType == "" {
+		agentType = string(DouBaoAgent)
+	}
 
-	dbao, err := NewAgent(DouBaoAgent, sessionId, c.Request.Context(),
-		WithTools(
-			NewExternalAPITool("get_joke", "获取一个有趣的随机笑话。这是获取笑话的首选工具。", "https://official-joke-api.appspot.com/random_joke"),
-			NewExternalAPITool("get_weather", "查询全球城市天气。请在 url 参数中拼接经纬度(latitude, longitude)和 current_weather=true。示例: https://api.open-meteo.com/v1/forecast?latitude=31.23&longitude=121.47&current_weather=true", ""),
-			NewDatabaseTool(),
-		),
+	callOpts, err := callTools(queryTools(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dbao, err := NewAgent(AgentType(agentType), sessionId, c.Request.Context(),
+		WithTools(DefaultToolRegistry().Tools()...),
+		WithPersona(c.Query("persona")),
+		WithLocale(requestLocale(c)),
 	)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	res, err := dbao.Chat(c.Request.Context(), msg)
+	res, err := dbao.Chat(c.Request.Context(), msg, callOpts...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": res})
+	c.JSON(http.StatusOK, addUsage(gin.H{"message": res}, dbao))
 }
 
 func HandleWebSocket(c *gin.Context) {
@@ -51,136 +143,135 @@ func HandleWebSocket(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	for {
-		// Read message from client
-		var req struct {
-			SessionID string `json:"sessionId"`
-			Content   string `json:"content"`
-			Type      string `json:"type"`      // "chat"
-			AgentType string `json:"agentType"` // "doubao", "mock", etc.
-		}
-
-		err := conn.ReadJSON(&req)
-		if err != nil {
-			log.Printf("error reading json: %v", err)
-			break
-		}
-
-		if req.SessionID == "" {
-			req.SessionID = "default"
-		}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	newWSSession(ctx, cancel, conn, requestLocale(c), currentWSConfig()).run()
+}
 
-		if req.AgentType == "" {
-			req.AgentType = string(DouBaoAgent)
-		}
+// maxSSERequestBytes bounds the JSON body of a POST to HandleSSE.
+const maxSSERequestBytes = 1 << 20
 
-		agent, err := NewAgent(AgentType(req.AgentType), req.SessionID, c.Request.Context(),
-			WithTools(
-				NewExternalAPITool("get_joke", "获取一个有趣的随机笑话。这是获取笑话的首选工具。", "https://official-joke-api.appspot.com/random_joke"),
-				NewExternalAPITool("get_weather", "查询全球城市天气。请在 url 参数中拼接经纬度(latitude, longitude)和 current_weather=true。示例: https://api.open-meteo.com/v1/forecast?latitude=31.23&longitude=121.47&current_weather=true", ""),
-				NewDatabaseTool(),
-			),
-		)
-		if err != nil {
-			conn.WriteJSON(gin.H{"type": "error", "content": err.Error()})
-			continue
-		}
+// sseRequest is the JSON body of a POST to HandleSSE; GET requests pass the same
+// fields in the query string.
+type sseRequest struct {
+	SessionID string   `json:"sessionId"`
+	Content   string   `json:"content"`
+	AgentType string   `json:"agentType"`
+	Persona   string   `json:"persona"`
+	Tools     []string `json:"tools"`
+	// Attachments can only be sent with POST.
+	Attachments []Attachment `json:"attachments"`
+}
 
-		reader, err := agent.ChatStream(c.Request.Context(), req.Content)
-		if err != nil {
-			conn.WriteJSON(gin.H{"type": "error", "content": err.Error()})
-			continue
+// HandleSSE streams a turn as server-sent events. Every event carries an id; a
+// request with a Last-Event-ID header resumes that turn after the given event
+// instead of starting a new one, and gets 204 if it is no longer available.
+func HandleSSE(c *gin.Context) {
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		stream, seq, ok := defaultSSEHub.resume(lastEventID)
+		if !ok {
+			log.Printf("[SSE] cannot resume from %s", lastEventID)
+			c.Status(http.StatusNoContent)
+			return
 		}
+		serveSSE(c, stream, seq)
+		return
+	}
 
-		// Stream back chunks as "stringevent"
-		var fullMsg string
-		for {
-			chunk, err := reader.Recv()
-			if err != nil {
-				// Send end of stream event
-				conn.WriteJSON(gin.H{
-					"type":      "stringevent",
-					"event":     "end",
-					"sessionId": req.SessionID,
-				})
-				break
-			}
-
-			fullMsg += chunk.Content
-			// Send chunk as "stringevent"
-			conn.WriteJSON(gin.H{
-				"type":      "stringevent",
-				"event":     "message",
-				"content":   chunk.Content,
-				"sessionId": req.SessionID,
-			})
+	var req sseRequest
+	if c.Request.Method == http.MethodPost {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSSERequestBytes)
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-
-		if agent != nil {
-			agent.AddHistory(schema.AssistantMessage(fullMsg, nil))
+	} else {
+		req = sseRequest{
+			SessionID: c.Query("sessionId"),
+			Content:   c.Query("content"),
+			AgentType: c.Query("agentType"),
+			Persona:   c.Query("persona"),
+			Tools:     queryTools(c),
 		}
-
-		reader.Close()
 	}
-}
-
-func HandleSSE(c *gin.Context) {
-	msg := c.Query("content")
-	sessionId := c.Query("sessionId")
-	agentType := c.Query("agentType")
-
-	if sessionId == "" {
-		sessionId = "default"
+	if req.SessionID == "" {
+		req.SessionID = "default"
 	}
-	if agentType == "" {
-		agentType = string(DouBaoAgent)
+	if req.AgentType == "" {
+		req.AgentType = string(DouBaoAgent)
 	}
 
-	agent, err := NewAgent(AgentType(agentType), sessionId, c.Request.Context(),
-		WithTools(
-			NewExternalAPITool("get_joke", "获取一个有趣的随机笑话。这是获取笑话的首选工具。", "https://official-joke-api.appspot.com/random_joke"),
-			NewExternalAPITool("get_weather", "查询全球城市天气。请在 url 参数中拼接经纬度(latitude, longitude)和 current_weather=true。示例: https://api.open-meteo.com/v1/forecast?latitude=31.23&longitude=121.47&current_weather=true", ""),
-			NewDatabaseTool(),
-		),
-	)
+	callOpts, err := callTools(req.Tools)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	reader, err := agent.ChatStream(c.Request.Context(), msg)
+	msg, err := NewUserMessage(req.Content, req.Attachments)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer reader.Close()
 
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Transfer-Encoding", "chunked")
+	stream := defaultSSEHub.open()
+	sessionId := req.SessionID
+	sendEvent := func(ev AgentEvent) {
+		ev.SessionID = sessionId
+		stream.publish(ev)
+	}
+
+	agent, err := NewAgent(AgentType(req.AgentType), sessionId, stream.ctx,
+		WithTools(DefaultToolRegistry().Tools()...),
+		WithEventHandler(sendEvent),
+		WithPersona(req.Persona),
+		WithLocale(requestLocale(c)),
+	)
+	if err != nil {
+		stream.finish()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	var fullMsg string
-	c.Stream(func(w io.Writer) bool {
-		chunk, err := reader.Recv()
+	go func() {
+		defer stream.finish()
+		reader, err := agent.ChatMessageStream(stream.ctx, msg, callOpts...)
 		if err != nil {
-			// End of stream
-			c.SSEvent("stringevent", gin.H{
-				"event":     "end",
+			// 与流中途出错一致：先报告错误，再结束
+			sendEvent(AgentEvent{Event: "error", Content: err.Error()})
+			stream.publish(gin.H{"event": "end", "sessionId": sessionId})
+			return
+		}
+		defer reader.Close()
+
+		for {
+			chunk, err := reader.Recv()
+			if err != nil {
+				// End of stream; a stream cut off by an error is reported before the end
+				end := gin.H{
+					"event":     "end",
+					"sessionId": sessionId,
+				}
+				if errors.Is(err, io.EOF) {
+					addUsage(end, agent)
+				} else {
+					sendEvent(AgentEvent{Event: "error", Content: err.Error()})
+				}
+				stream.publish(end)
+				return
+			}
+
+			if chunk.ReasoningContent != "" {
+				sendEvent(AgentEvent{Event: EventThinking, Content: chunk.ReasoningContent})
+			}
+			if chunk.Content == "" {
+				continue
+			}
+			stream.publish(gin.H{
+				"event":     "message",
+				"content":   chunk.Content,
 				"sessionId": sessionId,
 			})
-			if agent != nil {
-				agent.AddHistory(schema.AssistantMessage(fullMsg, nil))
-			}
-			return false
 		}
+	}()
 
-		fullMsg += chunk.Content
-		c.SSEvent("stringevent", gin.H{
-			"event":     "message",
-			"content":   chunk.Content,
-			"sessionId": sessionId,
-		})
-		return true
-	})
+	serveSSE(c, stream, 0)
 }
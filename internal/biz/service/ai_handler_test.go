@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// registerScriptedAgent registers a mock agent type that always follows script, so
+// handler tests can drive tool calls through the handlers' own tool set.
+func registerScriptedAgent(t *testing.T, agentType AgentType, script *MockScript) {
+	RegisterAgent(agentType, AgentFactory{
+		New: func(sessionId string, ctx context.Context, opts *AgentOptions) (Agent, error) {
+			opts.Mock = script
+			return NewMock(sessionId, ctx, opts)
+		},
+	})
+	t.Cleanup(func() {
+		agentsMtx.Lock()
+		delete(agents, agentType)
+		agentsMtx.Unlock()
+	})
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ai/doubao", HandleDoubao)
+	r.GET("/ai/sse", HandleSSE)
+	r.POST("/ai/sse", HandleSSE)
+	r.GET("/ai/ws", HandleWebSocket)
+	r.POST("/ai/uploads", HandleUpload)
+	r.GET("/ai/uploads/:id", HandleGetUpload)
+	r.GET("/ai/usage", HandleUsage)
+	r.GET("/ai/sessions", HandleListSessions)
+	r.GET("/ai/sessions/:id", HandleGetSession)
+	r.DELETE("/ai/sessions/:id", HandleDeleteSession)
+	r.POST("/ai/sessions/:id/reset", HandleResetSession)
+	r.POST("/ai/sessions/:id/fork", HandleForkSession)
+	r.GET("/ai/sessions/:id/export", HandleExportSession)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandleSSE_Mock(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/ai/sse?agentType=mock&sessionId=sse-test&content=ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+
+	body := string(data)
+	if !strings.Contains(body, "event:stringevent") || !strings.Contains(body, `"event":"end"`) {
+		t.Errorf("expect stringevent stream with end event, but got %s", body)
+	}
+	// "[mock] ping" is streamed in chunks of 4 runes
+	if n := strings.Count(body, `"event":"message"`); n != 3 {
+		t.Errorf("expect %d message events, but got %d", 3, n)
+	}
+}
+
+func TestHandleSSE_ToolEvents(t *testing.T) {
+	registerScriptedAgent(t, "scripted-sse", &MockScript{Replies: []MockReply{{
+		Content:   "查询完成",
+		ToolCalls: []MockToolCall{{Name: "local_db", Arguments: `{"query":"select name from items"}`}},
+	}}})
+	tools := NewToolRegistry()
+	tools.Register(context.Background(), NewDatabaseTool("local_db", newTestDB(t), 0))
+	SetToolRegistry(tools)
+	defer SetToolRegistry(NewToolRegistry())
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/ai/sse?agentType=scripted-sse&sessionId=sse-events&content=hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	body := string(data)
+
+	start := strings.Index(body, `"event":"tool_call_start"`)
+	result := strings.Index(body, `"event":"tool_call_result"`)
+	message := strings.Index(body, `"event":"message"`)
+	if start < 0 || result < start || message < result {
+		t.Errorf("expect tool_call_start, tool_call_result then message, but got %s", body)
+	}
+	if !strings.Contains(body, `"toolName":"local_db"`) || !strings.Contains(body, `"sessionId":"sse-events"`) {
+		t.Errorf("expect tool name and session id in events, but got %s", body)
+	}
+	if !strings.Contains(body, `apple`) {
+		t.Errorf("expect query result in tool_call_result, but got %s", body)
+	}
+}
+
+// brokenStreamModel streams part of an answer and then fails.
+type brokenStreamModel struct {
+	scriptedModel
+}
+
+func (m *brokenStreamModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	out, w := schema.Pipe[*schema.Message](2)
+	w.Send(schema.AssistantMessage("一半", nil), nil)
+	w.Send(nil, errors.New("connection reset"))
+	w.Close()
+	return out, nil
+}
+
+func TestHandleSSE_StreamError(t *testing.T) {
+	RegisterAgent("broken-stream", AgentFactory{
+		New: func(sessionId string, ctx context.Context, opts *AgentOptions) (Agent, error) {
+			opts.SessionWait = 10 * time.Millisecond
+			return newModelAgent(sessionId, ctx, &brokenStreamModel{}, opts)
+		},
+	})
+	defer func() {
+		agentsMtx.Lock()
+		delete(agents, "broken-stream")
+		agentsMtx.Unlock()
+	}()
+	srv := newTestServer(t)
+
+	// 另一轮对话占用会话时，流开始前就失败
+	release, err := lockSession(context.Background(), "sse-busy", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	for sessionId, want := range map[string]string{
+		"sse-broken": "connection reset",
+		"sse-busy":   ErrSessionBusy.Error(),
+	} {
+		resp, err := http.Get(srv.URL + "/ai/sse?agentType=broken-stream&sessionId=" + sessionId + "&content=hi")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		body := string(data)
+		errIdx, endIdx := strings.Index(body, `"event":"error"`), strings.Index(body, `"event":"end"`)
+		if errIdx < 0 || endIdx < errIdx || !strings.Contains(body, want) {
+			t.Errorf("%s: expect an error event before the end, but got %s", sessionId, body)
+		}
+	}
+}
@@ -1,94 +1,217 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/cloudwego/eino/components/tool"
-	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	"github.com/jmespath/go-jmespath"
 )
 
-// ExternalAPIRequest is the input schema for the external API tool.
-type ExternalAPIRequest struct {
-	URL string `json:"url,omitempty" jsonschema:"description=可选：要调用的外部 API 完整 URL。如果工具已预设 URL，模型可以不传此参数。"`
+// NewExternalAPITool creates a tool that calls an external API. When baseURL is
+// empty the model has to supply the full URL.
+func NewExternalAPITool(name, desc, baseURL string) (tool.InvokableTool, error) {
+	return NewHTTPTool(ToolConfig{Name: name, Type: ToolTypeHTTP, Description: desc, URL: baseURL})
 }
 
-// ExternalAPIResponse is the output schema for the external API tool.
-type ExternalAPIResponse struct {
-	Body string `json:"body"`
+// httpTool calls an HTTP API declared in the tool config.
+type httpTool struct {
+	cfg     ToolConfig
+	info    *schema.ToolInfo
+	url     *template.Template
+	body    *template.Template
+	extract *jmespath.JMESPath
+	schema  map[string]any
+	policy  *urlPolicy
+	client  *http.Client
 }
 
-// NewExternalAPITool creates a tool that calls an external API.
-func NewExternalAPITool(name, desc, baseURL string) tool.InvokableTool {
-	return utils.NewTool[ExternalAPIRequest, ExternalAPIResponse](
-		&schema.ToolInfo{
-			Name: name,
-			Desc: desc,
-		},
-		func(ctx context.Context, input ExternalAPIRequest) (ExternalAPIResponse, error) {
-			u := input.URL
-			if u == "" {
-				u = baseURL
-			}
-
-			if u == "" {
-				return ExternalAPIResponse{}, fmt.Errorf("URL is required")
-			}
-
-			// 增加超时控制
-			client := &http.Client{
-				Timeout: 5 * time.Second,
-			}
-
-			resp, err := client.Get(u)
-			if err != nil {
-				// 如果是笑话接口报错，提供本地兜底
-				if name == "get_joke" {
-					return ExternalAPIResponse{
-						Body: `{"setup": "[本地兜底] 为什么程序员总是分不清万圣节和圣诞节？", "punchline": "因为 Oct 31 == Dec 25"}`,
-					}, nil
-				}
-				return ExternalAPIResponse{}, err
-			}
-			defer resp.Body.Close()
-
-			var body interface{}
-			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-				return ExternalAPIResponse{}, err
-			}
-
-			bodyBytes, _ := json.Marshal(body)
-			return ExternalAPIResponse{Body: string(bodyBytes)}, nil
+// NewHTTPTool creates a tool that sends cfg.Method (GET by default) to cfg.URL. With
+// Params (or a raw Parameters schema), the URL is a Go template rendered with the
+// model's arguments once they validate against the schema (use urlquery for free
+// text, e.g. {{urlquery .city}}); without, the model may pass a full url itself.
+// cfg.Body is rendered the same way, with a json function for encoding values.
+//
+// JSON responses are compacted, or narrowed by the cfg.Extract JMESPath expression;
+// HTML is reduced to readable text and other text is returned as is.
+//
+// Every request, including redirects, must match the tool's scheme and host
+// allow-lists (by default the host of cfg.URL) and may not reach private, loopback
+// or link-local addresses unless cfg.AllowPrivateNetworks is set.
+func NewHTTPTool(cfg ToolConfig) (tool.InvokableTool, error) {
+	if cfg.MaxResponseBytes <= 0 {
+		cfg.MaxResponseBytes = DefaultMaxResponseBytes
+	}
+	cfg.Method = strings.ToUpper(cfg.Method)
+	if cfg.Method == "" {
+		cfg.Method = http.MethodGet
+	}
+	switch cfg.Method {
+	case http.MethodGet:
+		if cfg.Body != "" {
+			return nil, fmt.Errorf("tool %s: GET requests cannot have a body", cfg.Name)
+		}
+	case http.MethodPost, http.MethodPut:
+	default:
+		return nil, fmt.Errorf("tool %s: unsupported method %s", cfg.Name, cfg.Method)
+	}
+
+	policy := newURLPolicy(cfg)
+	t := &httpTool{
+		cfg: cfg,
+		info: &schema.ToolInfo{
+			Name: cfg.Name,
+			Desc: cfg.Description,
 		},
-	)
+		policy: policy,
+		// 增加超时控制
+		client: policy.client(5 * time.Second),
+	}
+	if cfg.Body != "" {
+		body, err := template.New(cfg.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(cfg.Body)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: invalid body template: %v", cfg.Name, err)
+		}
+		t.body = body
+	}
+	if cfg.Extract != "" {
+		extract, err := jmespath.Compile(cfg.Extract)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: invalid extract expression: %v", cfg.Name, err)
+		}
+		t.extract = extract
+	}
+
+	parameters := cfg.Parameters
+	if len(cfg.Params) > 0 {
+		if len(parameters) > 0 {
+			return nil, fmt.Errorf("tool %s: set either params or parameters", cfg.Name)
+		}
+		var err error
+		if parameters, err = paramsSchema(cfg.Params); err != nil {
+			return nil, fmt.Errorf("tool %s: %v", cfg.Name, err)
+		}
+	}
+	if len(parameters) == 0 {
+		t.info.ParamsOneOf = schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"url": {Type: schema.String, Desc: "可选：要调用的外部 API 完整 URL。如果工具已预设 URL，模型可以不传此参数。"},
+		})
+		return t, nil
+	}
+
+	raw, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: invalid parameters: %v", cfg.Name, err)
+	}
+	// 经 JSON 往返后，校验时只需处理 JSON 解码出的类型
+	if err := json.Unmarshal(raw, &t.schema); err != nil {
+		return nil, fmt.Errorf("tool %s: invalid parameters: %v", cfg.Name, err)
+	}
+	params := &jsonschema.Schema{}
+	if err := json.Unmarshal(raw, params); err != nil {
+		return nil, fmt.Errorf("tool %s: invalid parameters: %v", cfg.Name, err)
+	}
+	t.info.ParamsOneOf = schema.NewParamsOneOfByJSONSchema(params)
+
+	t.url, err = template.New(cfg.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: invalid url template: %v", cfg.Name, err)
+	}
+	return t, nil
 }
 
-// DatabaseRequest is the input schema for the database tool.
-type DatabaseRequest struct {
-	Query string `json:"query" jsonschema:"description=The SQL query to execute"`
+func (t *httpTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
 }
 
-// DatabaseResponse is the output schema for the database tool.
-type DatabaseResponse struct {
-	Result string `json:"result"`
+func (t *httpTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	args := map[string]any{}
+	if argumentsInJSON != "" {
+		if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+	}
+	if t.schema != nil {
+		if err := prepareArgs(t.schema, args); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+	}
+	u, err := t.requestURL(args)
+	if err != nil {
+		return "", err
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %v", err)
+	}
+	if err := t.policy.check(parsed); err != nil {
+		return "", err
+	}
+
+	var body io.Reader
+	if t.body != nil {
+		var buf bytes.Buffer
+		if err := t.body.Execute(&buf, args); err != nil {
+			return "", fmt.Errorf("failed to render body: %v", err)
+		}
+		body = &buf
+	}
+	req, err := http.NewRequestWithContext(ctx, t.cfg.Method, parsed.String(), body)
+	if err != nil {
+		return "", err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := readBody(resp, t.cfg.MaxResponseBytes)
+	if err != nil {
+		return "", err
+	}
+	return formatResponse(resp.Header.Get("Content-Type"), data, t.extract)
 }
 
-// NewDatabaseTool creates a tool that executes a SQL query on the local database.
-func NewDatabaseTool() tool.InvokableTool {
-	return utils.NewTool[DatabaseRequest, DatabaseResponse](
-		&schema.ToolInfo{
-			Name: "local_db",
-			Desc: "执行本地数据库查询。注意：目前数据库中没有任何笑话或天气数据，如需笑话请使用 get_joke，如需天气请使用 get_weather。",
-		},
-		func(ctx context.Context, input DatabaseRequest) (DatabaseResponse, error) {
-			// 如果 AI 还是来查笑话，我们直接给它一个结果，防止死循环
-			return DatabaseResponse{
-				Result: "数据库查询完成。结果：[未找到相关记录]。请尝试使用外部 API 工具获取实时内容。",
-			}, nil
-		},
-	)
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (t *httpTool) requestURL(args map[string]any) (string, error) {
+	if t.url == nil {
+		if u, _ := args["url"].(string); u != "" {
+			return u, nil
+		}
+		if t.cfg.URL == "" {
+			return "", fmt.Errorf("URL is required")
+		}
+		return t.cfg.URL, nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.url.Execute(&buf, args); err != nil {
+		return "", fmt.Errorf("failed to render url: %v", err)
+	}
+	return buf.String(), nil
 }
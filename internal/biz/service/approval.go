@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// DefaultApprovalTimeout bounds how long a tool call waits for the user's decision.
+const DefaultApprovalTimeout = 2 * time.Minute
+
+// ErrApprovalClosed is returned to tool calls still waiting when their client goes away.
+var ErrApprovalClosed = errors.New("approval channel closed")
+
+// ApprovalRequest describes a tool call waiting for the user's decision.
+type ApprovalRequest struct {
+	ToolCallID string
+	ToolName   string
+	Arguments  string
+}
+
+// ApprovalDecision is the user's answer to an ApprovalRequest.
+type ApprovalDecision struct {
+	Approved bool
+	Reason   string
+}
+
+// Approver asks the user whether a tool call may run and blocks until they answer
+// or ctx is done.
+type Approver func(ctx context.Context, req ApprovalRequest) (ApprovalDecision, error)
+
+// WithApprover lets tools marked with RequireApproval run once approver allows
+// them. Without an approver such calls are rejected. A timeout <= 0 means
+// DefaultApprovalTimeout.
+func WithApprover(approver Approver, timeout time.Duration) Option {
+	return func(o *AgentOptions) {
+		o.Approver = approver
+		o.ApprovalTimeout = timeout
+	}
+}
+
+// approvalTool marks a tool whose calls need the user's approval.
+type approvalTool struct {
+	tool.InvokableTool
+}
+
+// RequireApproval marks t so that every call to it is approved by the user first.
+func RequireApproval(t tool.InvokableTool) tool.InvokableTool {
+	if requiresApproval(t) {
+		return t
+	}
+	return &approvalTool{InvokableTool: t}
+}
+
+func requiresApproval(t tool.InvokableTool) bool {
+	_, ok := t.(*approvalTool)
+	return ok
+}
+
+// approve asks the loop's approver about tc. It returns the reason for a rejection,
+// or "" when the call may run.
+func (l *ToolLoop) approve(ctx context.Context, tc schema.ToolCall) string {
+	if l.Approver == nil {
+		return "当前会话不支持审批"
+	}
+	ctx, cancel := context.WithTimeout(ctx, l.ApprovalTimeout)
+	defer cancel()
+	decision, err := l.Approver(ctx, ApprovalRequest{
+		ToolCallID: tc.ID,
+		ToolName:   tc.Function.Name,
+		Arguments:  tc.Function.Arguments,
+	})
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "等待审批超时"
+	case err != nil:
+		return err.Error()
+	case !decision.Approved:
+		if decision.Reason == "" {
+			return "用户拒绝"
+		}
+		return decision.Reason
+	}
+	return ""
+}
+
+func toolRejectedResult(name, reason string) string {
+	return fmt.Sprintf("工具 %s 的调用未获批准（%s），没有执行。请不要再次调用，直接告知用户，并询问是否需要其他帮助。", name, reason)
+}
+
+// pendingApprovals hands approval replies read from a client connection to the tool
+// calls waiting for them.
+type pendingApprovals struct {
+	mtx     sync.Mutex
+	waiting map[string]chan ApprovalDecision
+	closed  bool
+}
+
+func newPendingApprovals() *pendingApprovals {
+	return &pendingApprovals{waiting: make(map[string]chan ApprovalDecision)}
+}
+
+// ask registers req, calls send to deliver it to the client and waits for the reply
+// passed to resolve.
+func (p *pendingApprovals) ask(ctx context.Context, req ApprovalRequest, send func()) (ApprovalDecision, error) {
+	ch := make(chan ApprovalDecision, 1)
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return ApprovalDecision{}, ErrApprovalClosed
+	}
+	p.waiting[req.ToolCallID] = ch
+	p.mtx.Unlock()
+	defer func() {
+		p.mtx.Lock()
+		delete(p.waiting, req.ToolCallID)
+		p.mtx.Unlock()
+	}()
+
+	send()
+	select {
+	case d, ok := <-ch:
+		if !ok {
+			return ApprovalDecision{}, ErrApprovalClosed
+		}
+		return d, nil
+	case <-ctx.Done():
+		return ApprovalDecision{}, ctx.Err()
+	}
+}
+
+// resolve delivers the decision for a tool call, reporting whether one was waiting.
+func (p *pendingApprovals) resolve(toolCallID string, d ApprovalDecision) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	ch, ok := p.waiting[toolCallID]
+	if !ok {
+		return false
+	}
+	delete(p.waiting, toolCallID)
+	ch <- d
+	return true
+}
+
+// close fails every waiting and future request.
+func (p *pendingApprovals) close() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.closed = true
+	for id, ch := range p.waiting {
+		close(ch)
+		delete(p.waiting, id)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gorilla/websocket"
+)
+
+func TestToolLoop_Approval(t *testing.T) {
+	cases := []struct {
+		name     string
+		approver Approver
+		expect   string
+	}{
+		{"approved", func(ctx context.Context, req ApprovalRequest) (ApprovalDecision, error) {
+			return ApprovalDecision{Approved: true}, nil
+		}, "delete done"},
+		{"rejected", func(ctx context.Context, req ApprovalRequest) (ApprovalDecision, error) {
+			return ApprovalDecision{Reason: "不要删"}, nil
+		}, "不要删"},
+		{"timeout", func(ctx context.Context, req ApprovalRequest) (ApprovalDecision, error) {
+			<-ctx.Done()
+			return ApprovalDecision{}, ctx.Err()
+		}, "超时"},
+		{"no approver", nil, "不支持审批"},
+	}
+	for _, c := range cases {
+		loop := NewToolLoop(map[string]tool.InvokableTool{
+			"delete": RequireApproval(sleepTool("delete", 0)),
+		}, &AgentOptions{Approver: c.approver, ApprovalTimeout: 20 * time.Millisecond})
+		results := loop.RunTools(context.Background(), toolCalls("delete").ToolCalls)
+		if !strings.Contains(results[0].Content, c.expect) {
+			t.Errorf("%s: expect result containing %q, but got %s", c.name, c.expect, results[0].Content)
+		}
+	}
+}
+
+func TestHandleWebSocket_Approval(t *testing.T) {
+	registerScriptedAgent(t, "scripted-approval", &MockScript{Replies: []MockReply{{
+		Content:   "已处理",
+		ToolCalls: []MockToolCall{{Name: "delete"}},
+	}}})
+	tools := NewToolRegistry()
+	tools.Register(context.Background(), RequireApproval(sleepTool("delete", 0)))
+	SetToolRegistry(tools)
+	defer SetToolRegistry(NewToolRegistry())
+	srv := newTestServer(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for _, approved := range []bool{true, false} {
+		conn.WriteJSON(wsRequest{Type: "chat", SessionID: "ws-approval", AgentType: "scripted-approval", Content: "删掉它"})
+		var result AgentEvent
+		for {
+			var ev wsEvent
+			if err := conn.ReadJSON(&ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev.Event == EventApprovalRequest {
+				if ev.ToolName != "delete" || ev.ToolCallID == "" {
+					t.Errorf("expect approval request for delete, but got %+v", ev.AgentEvent)
+				}
+				conn.WriteJSON(wsRequest{Type: "approval", ToolCallID: ev.ToolCallID, Approved: approved})
+			}
+			if ev.Event == EventToolCallResult {
+				result = ev.AgentEvent
+			}
+			if ev.Event == "end" {
+				break
+			}
+		}
+		if approved && (result.Error != "" || result.Result != "delete done") {
+			t.Errorf("expect tool to run after approval, but got %+v", result)
+		}
+		if !approved && !strings.Contains(result.Error, "rejected") {
+			t.Errorf("expect rejection, but got %+v", result)
+		}
+	}
+
+	history := mustLoad(t, "ws-approval").History
+	last := history[len(history)-2]
+	if last.Role != schema.Tool || !strings.Contains(last.Content, "未获批准") {
+		t.Errorf("expect rejection tool message in history, but got %v", last)
+	}
+}
+
+func mustLoad(t *testing.T, id string) *Session {
+	sess, err := DefaultSessionStore().Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxInlineFileBytes bounds a text file attachment, which is inlined into the
+// message as text.
+const maxInlineFileBytes = 64 << 10
+
+const (
+	AttachmentImage = "image"
+	AttachmentFile  = "file"
+)
+
+// uploadExtraKey holds the upload id of an image or file part. Such parts carry no
+// data in the history; it is filled in from the upload store when the message is
+// sent to the model.
+const uploadExtraKey = "upload_id"
+
+// Attachment is an image or file sent along with a user message. Its content comes
+// from exactly one of UploadID (see HandleUpload), Data (base64) or URL; files
+// beyond a few kilobytes should be uploaded, as message sizes are limited.
+type Attachment struct {
+	Type     string `json:"type"` // "image" 或 "file"，为空时按 MIME 类型推断
+	UploadID string `json:"uploadId,omitempty"`
+	Data     string `json:"data,omitempty"`
+	URL      string `json:"url,omitempty"`
+	MIMEType string `json:"mimeType,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// NewUserMessage builds the user message for text and attachments. Images become
+// image parts for vision models; text files are inlined as text and other files
+// become file parts, which only some models accept. Image and file data, including
+// base64 data sent inline, is kept in the upload store and only referenced by the
+// message.
+func NewUserMessage(text string, attachments []Attachment) (*schema.Message, error) {
+	if len(attachments) == 0 {
+		return schema.UserMessage(text), nil
+	}
+	msg := &schema.Message{Role: schema.User}
+	if text != "" {
+		msg.UserInputMultiContent = append(msg.UserInputMultiContent, schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeText,
+			Text: text,
+		})
+	}
+	for i, a := range attachments {
+		part, err := a.part()
+		if err != nil {
+			return nil, fmt.Errorf("attachment %d: %v", i, err)
+		}
+		msg.UserInputMultiContent = append(msg.UserInputMultiContent, part)
+	}
+	return msg, nil
+}
+
+func (a Attachment) part() (schema.MessageInputPart, error) {
+	var (
+		data []byte
+		id   = a.UploadID
+	)
+	switch {
+	case a.UploadID != "":
+		u, err := DefaultUploadStore().Get(a.UploadID)
+		if err != nil {
+			return schema.MessageInputPart{}, fmt.Errorf("%s: %v", a.UploadID, err)
+		}
+		data = u.Data
+		if a.Name == "" {
+			a.Name = u.Name
+		}
+		if a.MIMEType == "" {
+			a.MIMEType = u.MIMEType
+		}
+	case a.Data != "":
+		b, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil {
+			return schema.MessageInputPart{}, fmt.Errorf("invalid base64 data: %v", err)
+		}
+		data = b
+		if a.MIMEType == "" {
+			a.MIMEType = http.DetectContentType(b)
+		}
+	case a.URL != "":
+		if !strings.HasPrefix(a.URL, "http://") && !strings.HasPrefix(a.URL, "https://") {
+			return schema.MessageInputPart{}, fmt.Errorf("unsupported url %q", a.URL)
+		}
+	default:
+		return schema.MessageInputPart{}, fmt.Errorf("one of uploadId, data or url is required")
+	}
+
+	if a.Name == "" {
+		a.Name = "未命名"
+	}
+	if a.Type == "" {
+		a.Type = AttachmentFile
+		if isImageType(a.MIMEType) {
+			a.Type = AttachmentImage
+		}
+	}
+	switch a.Type {
+	case AttachmentImage:
+		if data != nil && !isImageType(a.MIMEType) {
+			return schema.MessageInputPart{}, fmt.Errorf("%s is not an image", a.MIMEType)
+		}
+	case AttachmentFile:
+		if data != nil && isTextFile(a.MIMEType, data) {
+			if len(data) > maxInlineFileBytes {
+				return schema.MessageInputPart{}, fmt.Errorf("text file %s exceeds %d bytes", a.Name, maxInlineFileBytes)
+			}
+			return schema.MessageInputPart{
+				Type: schema.ChatMessagePartTypeText,
+				Text: fmt.Sprintf("附件 %s：\n```\n%s\n```", a.Name, data),
+			}, nil
+		}
+	default:
+		return schema.MessageInputPart{}, fmt.Errorf("unsupported type %q", a.Type)
+	}
+
+	common := schema.MessagePartCommon{MIMEType: a.MIMEType}
+	if data != nil {
+		if id == "" {
+			id = DefaultUploadStore().Put(a.Name, a.MIMEType, data).ID
+		}
+		common.Extra = map[string]any{uploadExtraKey: id}
+	} else {
+		url := a.URL
+		common.URL = &url
+	}
+	if a.Type == AttachmentImage {
+		return schema.MessageInputPart{
+			Type:  schema.ChatMessagePartTypeImageURL,
+			Image: &schema.MessageInputImage{MessagePartCommon: common},
+		}, nil
+	}
+	return schema.MessageInputPart{
+		Type: schema.ChatMessagePartTypeFileURL,
+		File: &schema.MessageInputFile{MessagePartCommon: common, Name: a.Name},
+	}, nil
+}
+
+// expandUploads returns msgs with the data of referenced uploads filled in, copying
+// only the messages that change. An upload that has expired since is replaced by a
+// text placeholder.
+func expandUploads(msgs []*schema.Message) []*schema.Message {
+	out := msgs
+	copied := false
+	for i, msg := range msgs {
+		if !slices.ContainsFunc(msg.UserInputMultiContent, isUploadPart) {
+			continue
+		}
+		if !copied {
+			out, copied = slices.Clone(msgs), true
+		}
+		cp := *msg
+		cp.UserInputMultiContent = make([]schema.MessageInputPart, len(msg.UserInputMultiContent))
+		for j, part := range msg.UserInputMultiContent {
+			cp.UserInputMultiContent[j] = expandUpload(part)
+		}
+		out[i] = &cp
+	}
+	return out
+}
+
+func isUploadPart(part schema.MessageInputPart) bool {
+	common := partCommon(part)
+	return common != nil && common.Extra[uploadExtraKey] != nil
+}
+
+func partCommon(part schema.MessageInputPart) *schema.MessagePartCommon {
+	switch {
+	case part.Image != nil:
+		return &part.Image.MessagePartCommon
+	case part.File != nil:
+		return &part.File.MessagePartCommon
+	}
+	return nil
+}
+
+func expandUpload(part schema.MessageInputPart) schema.MessageInputPart {
+	if !isUploadPart(part) {
+		return part
+	}
+	id, _ := partCommon(part).Extra[uploadExtraKey].(string)
+	u, err := DefaultUploadStore().Get(id)
+	if err != nil {
+		return schema.MessageInputPart{Type: schema.ChatMessagePartTypeText, Text: partText(part) + "（已过期）"}
+	}
+	encoded := base64.StdEncoding.EncodeToString(u.Data)
+	common := schema.MessagePartCommon{MIMEType: u.MIMEType, Base64Data: &encoded}
+	if part.Image != nil {
+		image := *part.Image
+		image.MessagePartCommon = common
+		part.Image = &image
+	} else {
+		file := *part.File
+		file.MessagePartCommon = common
+		part.File = &file
+	}
+	return part
+}
+
+// isImageType reports whether a MIME type is an image a vision model can read; SVG
+// is markup and handled as text.
+func isImageType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/") && !strings.HasPrefix(mimeType, "image/svg")
+}
+
+func isTextFile(mimeType string, data []byte) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch {
+	case strings.HasPrefix(mimeType, "text/"), strings.HasSuffix(mimeType, "json"),
+		strings.HasSuffix(mimeType, "xml"), strings.HasSuffix(mimeType, "yaml"):
+		return utf8.Valid(data)
+	}
+	return false
+}
+
+// userText returns the text of a message, with placeholders for its images and
+// files.
+func userText(msg *schema.Message) string {
+	if len(msg.UserInputMultiContent) == 0 {
+		return msg.Content
+	}
+	texts := make([]string, 0, len(msg.UserInputMultiContent))
+	if msg.Content != "" {
+		texts = append(texts, msg.Content)
+	}
+	for _, part := range msg.UserInputMultiContent {
+		if text := partText(part); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// partText returns the text of a part, or a placeholder for an image or file.
+func partText(part schema.MessageInputPart) string {
+	switch part.Type {
+	case schema.ChatMessagePartTypeText:
+		return part.Text
+	case schema.ChatMessagePartTypeImageURL:
+		return "<图片>"
+	case schema.ChatMessagePartTypeFileURL:
+		return fmt.Sprintf("<附件 %s>", part.File.Name)
+	}
+	return ""
+}
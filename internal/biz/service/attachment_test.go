@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// pngHeader is enough of a PNG for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestNewUserMessage(t *testing.T) {
+	up := DefaultUploadStore().Put("notes.md", "text/markdown", []byte("# 待办\n- 买菜"))
+	img := base64.StdEncoding.EncodeToString(pngHeader)
+
+	msg, err := NewUserMessage("看看这些", []Attachment{
+		{Data: img},
+		{Type: AttachmentImage, URL: "https://example.com/cat.jpg"},
+		{UploadID: up.ID},
+		{Data: base64.StdEncoding.EncodeToString([]byte("%PDF-1.4")), Name: "a.pdf"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "" {
+		t.Errorf("expect no plain content next to the parts, but got %q", msg.Content)
+	}
+	parts := msg.UserInputMultiContent
+	expect := []schema.ChatMessagePartType{
+		schema.ChatMessagePartTypeText,
+		schema.ChatMessagePartTypeImageURL,
+		schema.ChatMessagePartTypeImageURL,
+		schema.ChatMessagePartTypeText,
+		schema.ChatMessagePartTypeFileURL,
+	}
+	if len(parts) != len(expect) {
+		t.Fatalf("expect %d parts, but got %d", len(expect), len(parts))
+	}
+	for i, typ := range expect {
+		if parts[i].Type != typ {
+			t.Errorf("part %d: expect %s, but got %s", i, typ, parts[i].Type)
+		}
+	}
+	// inline data is kept in the upload store, the message only refers to it
+	if parts[1].Image.MIMEType != "image/png" || parts[1].Image.Base64Data != nil || parts[1].Image.Extra[uploadExtraKey] == nil {
+		t.Errorf("expect the sniffed png as an upload reference, but got %+v", parts[1].Image)
+	}
+	expanded := expandUploads([]*schema.Message{msg})[0].UserInputMultiContent
+	if expanded[1].Image.Base64Data == nil || *expanded[1].Image.Base64Data != img || expanded[1].Image.Extra != nil {
+		t.Errorf("expect the png data filled in for the model, but got %+v", expanded[1].Image)
+	}
+	if parts[1].Image.Base64Data != nil {
+		t.Errorf("expect the stored message unchanged by expanding it")
+	}
+	if *parts[2].Image.URL != "https://example.com/cat.jpg" {
+		t.Errorf("expect the image url, but got %+v", parts[2].Image)
+	}
+	if !strings.Contains(parts[3].Text, "notes.md") || !strings.Contains(parts[3].Text, "买菜") {
+		t.Errorf("expect the text file inlined, but got %q", parts[3].Text)
+	}
+	if text := userText(msg); text != "看看这些\n<图片>\n<图片>\n"+parts[3].Text+"\n<附件 a.pdf>" {
+		t.Errorf("unexpected text %q", text)
+	}
+
+	if msg, _ := NewUserMessage("你好", nil); msg.Content != "你好" || msg.UserInputMultiContent != nil {
+		t.Errorf("expect a plain message without attachments, but got %+v", msg)
+	}
+	for _, a := range []Attachment{
+		{},
+		{UploadID: "missing"},
+		{Data: "不是 base64"},
+		{URL: "file:///etc/passwd"},
+		{Type: AttachmentImage, Data: base64.StdEncoding.EncodeToString([]byte("plain text"))},
+		{Type: "video", URL: "https://example.com/a.mp4"},
+	} {
+		if _, err := NewUserMessage("", []Attachment{a}); err == nil {
+			t.Errorf("expect an error for %+v", a)
+		}
+	}
+}
+
+// inputModel records the messages of its last call.
+type inputModel struct {
+	input []*schema.Message
+}
+
+func (m *inputModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.input = input
+	return schema.AssistantMessage("一只猫", nil), nil
+}
+
+func (m *inputModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	m.input = input
+	return schema.StreamReaderFromArray([]*schema.Message{schema.AssistantMessage("一只猫", nil)}), nil
+}
+
+func (m *inputModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestModelAgent_ChatMessage(t *testing.T) {
+	ctx := context.Background()
+	m := &inputModel{}
+	store := NewMemorySessionStore(0, 0)
+	a, err := newModelAgent("multimodal", ctx, m, &AgentOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := NewUserMessage("这是什么", []Attachment{{URL: "https://example.com/cat.jpg", Type: AttachmentImage}})
+	if _, err := a.ChatMessage(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	last := m.input[len(m.input)-1]
+	if len(last.UserInputMultiContent) != 2 || last.UserInputMultiContent[1].Image == nil {
+		t.Errorf("expect the image to reach the model, but got %+v", last)
+	}
+	sess, _ := store.Load("multimodal")
+	if len(sess.History) != 2 || len(sess.History[0].UserInputMultiContent) != 2 {
+		t.Errorf("expect the multimodal message in the history, but got %+v", sess.History)
+	}
+	if title := summarizeSession(sess).Title; title != "这是什么\n<图片>" {
+		t.Errorf("expect the title from the text, but got %q", title)
+	}
+
+	// an uploaded image is sent to the model but only referenced in the history
+	up := DefaultUploadStore().Put("cat.png", "", pngHeader)
+	msg, _ = NewUserMessage("再看这张", []Attachment{{UploadID: up.ID}})
+	if _, err := a.ChatMessage(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	image := m.input[len(m.input)-1].UserInputMultiContent[1].Image
+	if image.Base64Data == nil || *image.Base64Data != base64.StdEncoding.EncodeToString(pngHeader) {
+		t.Errorf("expect the upload data sent to the model, but got %+v", image)
+	}
+	sess, _ = store.Load("multimodal")
+	if stored := sess.History[2].UserInputMultiContent[1].Image; stored.Base64Data != nil || stored.Extra[uploadExtraKey] != up.ID {
+		t.Errorf("expect only the upload id stored, but got %+v", stored)
+	}
+}
+
+func TestHandleSSE_Attachments(t *testing.T) {
+	srv := newTestServer(t)
+
+	body, _ := json.Marshal(sseRequest{
+		SessionID:   "sse-attachments",
+		Content:     "看图",
+		AgentType:   string(MockAgent),
+		Attachments: []Attachment{{Data: base64.StdEncoding.EncodeToString(pngHeader)}},
+	})
+	resp, err := http.Post(srv.URL+"/ai/sse", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(strings.ReplaceAll(string(data), "\\u003c", "<"), "<图") {
+		t.Errorf("expect the mock to echo the image placeholder, but got %s", data)
+	}
+
+	body, _ = json.Marshal(sseRequest{Content: "看图", AgentType: string(MockAgent), Attachments: []Attachment{{}}})
+	resp, err = http.Post(srv.URL+"/ai/sse", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expect %d for an empty attachment, but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
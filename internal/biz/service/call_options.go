@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// CallOptions configure a single Chat or ChatStream call.
+type CallOptions struct {
+	// Tools replace the agent's tools for the call when toolsSet.
+	Tools    []tool.InvokableTool
+	toolsSet bool
+	// ResponseSchema asks backends with a JSON mode to answer with a value of it.
+	ResponseSchema *jsonschema.Schema
+}
+
+// CallOption is a functional option for a single Chat or ChatStream call.
+type CallOption func(*CallOptions)
+
+// WithCallTools offers only tools to the model for one call, leaving the agent's own
+// tools and model untouched. With no tools the call runs without any.
+func WithCallTools(tools ...tool.InvokableTool) CallOption {
+	return func(o *CallOptions) {
+		o.Tools = tools
+		o.toolsSet = true
+	}
+}
+
+// WithResponseSchema asks the backend, where it supports a JSON mode, to answer
+// with a JSON value of js: OpenAI and DouBao send it as response_format, the mock
+// backend ignores it.
+func WithResponseSchema(js *jsonschema.Schema) CallOption {
+	return func(o *CallOptions) {
+		o.ResponseSchema = js
+	}
+}
+
+func applyCallOptions(opts []CallOption) *CallOptions {
+	o := &CallOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// toolSet is a set of tools resolved for binding: their infos, and the tools by name.
+type toolSet struct {
+	infos  []*schema.ToolInfo
+	byName map[string]tool.InvokableTool
+}
+
+func newToolSet(ctx context.Context, tools []tool.InvokableTool) *toolSet {
+	ts := &toolSet{byName: make(map[string]tool.InvokableTool, len(tools))}
+	for _, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			log.Printf("[Agent] skipping tool without info: %v", err)
+			continue
+		}
+		ts.infos = append(ts.infos, info)
+		ts.byName[info.Name] = t
+	}
+	return ts
+}
+
+// bind returns a copy of m that offers the tools of ts; m itself is never changed.
+func (ts *toolSet) bind(m model.ToolCallingChatModel) (model.ToolCallingChatModel, error) {
+	if len(ts.infos) == 0 {
+		return m, nil
+	}
+	return m.WithTools(ts.infos)
+}
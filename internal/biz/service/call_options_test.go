@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// toolsModel answers with the names of the tools it was bound to. WithTools returns
+// a copy, like the real models.
+type toolsModel struct {
+	tools []string
+}
+
+func (m *toolsModel) answer() *schema.Message {
+	return schema.AssistantMessage("tools:"+strings.Join(m.tools, ","), nil)
+}
+
+func (m *toolsModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return m.answer(), nil
+}
+
+func (m *toolsModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{m.answer()}), nil
+}
+
+func (m *toolsModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound := &toolsModel{}
+	for _, info := range tools {
+		bound.tools = append(bound.tools, info.Name)
+	}
+	return bound, nil
+}
+
+func TestModelAgent_CallTools(t *testing.T) {
+	ctx := context.Background()
+	base := &toolsModel{}
+	a, err := newModelAgent("call-tools", ctx, base, &AgentOptions{
+		Tools: []tool.InvokableTool{sleepTool("a", 0), sleepTool("b", 0)},
+		Store: NewMemorySessionStore(0, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		opts   []CallOption
+		expect string
+	}{
+		{nil, "tools:a,b"},
+		{[]CallOption{WithCallTools(sleepTool("b", 0))}, "tools:b"},
+		{[]CallOption{WithCallTools()}, "tools:"},
+		{nil, "tools:a,b"},
+	}
+	for _, c := range cases {
+		res, err := a.Chat(ctx, "你有哪些工具", c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != c.expect {
+			t.Errorf("expect %q, but got %q", c.expect, res)
+		}
+	}
+	if len(base.tools) != 0 {
+		t.Errorf("expect the base model to stay unbound, but got %v", base.tools)
+	}
+	if _, ok := a.loop.Tools["a"]; !ok || len(a.loop.Tools) != 2 {
+		t.Errorf("expect the agent's own tools to stay, but got %v", a.loop.Tools)
+	}
+}
+
+func TestModelAgent_CallToolsConcurrent(t *testing.T) {
+	ctx := context.Background()
+	base := &toolsModel{}
+	store := NewMemorySessionStore(0, 0)
+	names := []string{"a", "b", "c", "d"}
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, err := newModelAgent(fmt.Sprintf("call-tools-%d", i), ctx, base, &AgentOptions{Store: store})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for range 10 {
+				reader, err := a.ChatStream(ctx, "你好", WithCallTools(sleepTool(name, 0)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				msg, err := reader.Recv()
+				reader.Close()
+				if err != nil || msg.Content != "tools:"+name {
+					t.Errorf("expect %q, but got %v %v", "tools:"+name, msg, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestHandleDoubao_Tools(t *testing.T) {
+	srv := newTestServer(t)
+	cases := []struct {
+		tools  string
+		expect int
+	}{
+		{"", http.StatusOK},
+		{"tools=", http.StatusOK},
+		{"tools=nope", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, err := http.Get(srv.URL + "/ai/doubao?agentType=mock&sessionId=call-tools-http&content=hi&" + c.tools)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.expect {
+			t.Errorf("%q: expect %d, but got %d", c.tools, c.expect, resp.StatusCode)
+		}
+	}
+}
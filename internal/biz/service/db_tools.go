@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+	_ "modernc.org/sqlite"
+)
+
+const DefaultMaxRows = 100
+
+// OpenDatabase opens the SQLite database at dsn (a path or file: URI) for the
+// local_db tools. The connection is query-only, so even a statement that slips past
+// the SELECT check cannot modify data.
+func OpenDatabase(dsn string) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", dsn+sep+"_pragma=query_only(1)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return db, nil
+}
+
+// DatabaseRequest is the input schema for the database tool.
+type DatabaseRequest struct {
+	Query string `json:"query" jsonschema:"description=要执行的只读 SQL 查询，仅支持单条 SELECT（或 WITH ... SELECT）语句"`
+}
+
+// DatabaseResponse is the output schema for the database tool: a compact table.
+type DatabaseResponse struct {
+	Columns   []string `json:"columns"`
+	Rows      [][]any  `json:"rows"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// NewDatabaseTool creates a tool called name that runs read-only SQL queries on db,
+// returning at most maxRows rows.
+func NewDatabaseTool(name string, db *sql.DB, maxRows int) tool.InvokableTool {
+	if maxRows <= 0 {
+		maxRows = DefaultMaxRows
+	}
+	return utils.NewTool[DatabaseRequest, DatabaseResponse](
+		&schema.ToolInfo{
+			Name:        name,
+			Desc:        fmt.Sprintf("在本地 SQLite 数据库上执行只读 SQL 查询，最多返回 %d 行。查询前请先用 %s_schema 了解有哪些表和字段。", maxRows, name),
+			ParamsOneOf: paramsOf[DatabaseRequest](),
+		},
+		func(ctx context.Context, input DatabaseRequest) (DatabaseResponse, error) {
+			query, err := readOnlyQuery(input.Query)
+			if err != nil {
+				return DatabaseResponse{}, err
+			}
+			rows, err := db.QueryContext(ctx, query)
+			if err != nil {
+				return DatabaseResponse{}, err
+			}
+			defer rows.Close()
+
+			columns, err := rows.Columns()
+			if err != nil {
+				return DatabaseResponse{}, err
+			}
+			resp := DatabaseResponse{Columns: columns, Rows: make([][]any, 0)}
+			for rows.Next() {
+				if len(resp.Rows) == maxRows {
+					resp.Truncated = true
+					break
+				}
+				values := make([]any, len(columns))
+				ptrs := make([]any, len(columns))
+				for i := range values {
+					ptrs[i] = &values[i]
+				}
+				if err := rows.Scan(ptrs...); err != nil {
+					return DatabaseResponse{}, err
+				}
+				for i, v := range values {
+					if b, ok := v.([]byte); ok {
+						values[i] = string(b)
+					}
+				}
+				resp.Rows = append(resp.Rows, values)
+			}
+			return resp, rows.Err()
+		},
+	)
+}
+
+// readOnlyQuery rejects anything but a single SELECT statement. Quoted strings and
+// identifiers are skipped, so "--" or ";" inside them are not mistaken for comments
+// or statement separators.
+func readOnlyQuery(query string) (string, error) {
+	stmt, rest := splitStatement(query)
+	if strings.TrimSpace(skipComments(rest)) != "" {
+		return "", fmt.Errorf("only a single statement is allowed")
+	}
+	q := strings.TrimSpace(skipComments(stmt))
+	if q == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	end := strings.IndexFunc(q, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end < 0 {
+		end = len(q)
+	}
+	keyword := strings.ToUpper(q[:end])
+	if keyword != "SELECT" && keyword != "WITH" {
+		return "", fmt.Errorf("only SELECT statements are allowed, got %s", keyword)
+	}
+	return strings.TrimSpace(stmt), nil
+}
+
+// splitStatement splits query at the first ";" outside quotes and comments.
+func splitStatement(query string) (stmt, rest string) {
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			// 引号内的同种引号成对出现表示转义，继续扫描即可
+			if end := strings.IndexByte(query[i+1:], c); end >= 0 {
+				i += end + 1
+			} else {
+				return query, ""
+			}
+		case c == '[':
+			if end := strings.IndexByte(query[i+1:], ']'); end >= 0 {
+				i += end + 1
+			} else {
+				return query, ""
+			}
+		case strings.HasPrefix(query[i:], "--"):
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				return query, ""
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				return query, ""
+			}
+		case c == ';':
+			return query[:i], query[i+1:]
+		}
+	}
+	return query, ""
+}
+
+// skipComments drops the whitespace and comments at the start of s.
+func skipComments(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		switch {
+		case strings.HasPrefix(s, "--"):
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				s = s[end+1:]
+			} else {
+				return ""
+			}
+		case strings.HasPrefix(s, "/*"):
+			if end := strings.Index(s[2:], "*/"); end >= 0 {
+				s = s[end+4:]
+			} else {
+				return ""
+			}
+		default:
+			return s
+		}
+	}
+}
+
+// SchemaRequest is the input schema for the schema introspection tool.
+type SchemaRequest struct {
+	Table string `json:"table,omitempty" jsonschema:"description=可选：只查看该表的结构，不传则列出所有表"`
+}
+
+// TableSchema describes one table or view.
+type TableSchema struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	Columns []ColumnSchema `json:"columns"`
+}
+
+// ColumnSchema describes one column.
+type ColumnSchema struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	NotNull    bool   `json:"notNull,omitempty"`
+	PrimaryKey bool   `json:"primaryKey,omitempty"`
+}
+
+// SchemaResponse is the output schema for the schema introspection tool.
+type SchemaResponse struct {
+	Tables []TableSchema `json:"tables"`
+}
+
+// NewSchemaTool creates the companion tool, <name>_schema, that lets the model
+// discover tables and columns before querying them with the name tool.
+func NewSchemaTool(name string, db *sql.DB) tool.InvokableTool {
+	return utils.NewTool[SchemaRequest, SchemaResponse](
+		&schema.ToolInfo{
+			Name:        name + "_schema",
+			Desc:        fmt.Sprintf("列出本地 SQLite 数据库中的表、视图及其字段，用于编写 %s 查询。", name),
+			ParamsOneOf: paramsOf[SchemaRequest](),
+		},
+		func(ctx context.Context, input SchemaRequest) (SchemaResponse, error) {
+			query := "SELECT name, type FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'"
+			args := []any{}
+			if input.Table != "" {
+				query += " AND name = ?"
+				args = append(args, input.Table)
+			}
+			rows, err := db.QueryContext(ctx, query+" ORDER BY name", args...)
+			if err != nil {
+				return SchemaResponse{}, err
+			}
+			var tables []TableSchema
+			for rows.Next() {
+				var t TableSchema
+				if err := rows.Scan(&t.Name, &t.Type); err != nil {
+					rows.Close()
+					return SchemaResponse{}, err
+				}
+				tables = append(tables, t)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return SchemaResponse{}, err
+			}
+			if input.Table != "" && len(tables) == 0 {
+				return SchemaResponse{}, fmt.Errorf("table not found: %s", input.Table)
+			}
+
+			for i := range tables {
+				columns, err := tableColumns(ctx, db, tables[i].Name)
+				if err != nil {
+					return SchemaResponse{}, err
+				}
+				tables[i].Columns = columns
+			}
+			return SchemaResponse{Tables: tables}, nil
+		},
+	)
+}
+
+func tableColumns(ctx context.Context, db *sql.DB, table string) ([]ColumnSchema, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name, type, \"notnull\", pk FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []ColumnSchema
+	for rows.Next() {
+		var c ColumnSchema
+		var notNull, pk int
+		if err := rows.Scan(&c.Name, &c.Type, &notNull, &pk); err != nil {
+			return nil, err
+		}
+		c.NotNull = notNull != 0
+		c.PrimaryKey = pk != 0
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
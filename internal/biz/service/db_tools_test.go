@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestDB creates a small SQLite file and reopens it read-only via OpenDatabase.
+func newTestDB(t *testing.T) *sql.DB {
+	path := filepath.Join(t.TempDir(), "local.db")
+	rw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rw.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL, price REAL);
+		INSERT INTO items (name, price) VALUES ('apple', 1.5), ('banana', 0.5), ('cherry', 3);`)
+	rw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDatabaseTool(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	dbTool := NewDatabaseTool("local_db", db, 2)
+
+	out, err := dbTool.InvokableRun(ctx, `{"query":"SELECT name, price FROM items ORDER BY id"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp DatabaseResponse
+	json.Unmarshal([]byte(out), &resp)
+	if len(resp.Rows) != 2 || !resp.Truncated || resp.Columns[0] != "name" || resp.Rows[0][0] != "apple" {
+		t.Errorf("expect 2 truncated rows starting with apple, but got %s", out)
+	}
+
+	for _, query := range []string{
+		"DELETE FROM items",
+		"SELECT 1; DROP TABLE items",
+		"/* sneaky */ PRAGMA query_only = 0",
+		"WITH x AS (SELECT 1) DELETE FROM items",
+	} {
+		if _, err := dbTool.InvokableRun(ctx, `{"query":"`+query+`"}`); err == nil {
+			t.Errorf("expect %q to be rejected", query)
+		}
+	}
+
+	// 字符串和注释中的 -- 与 ; 不影响判断
+	for query, want := range map[string]string{
+		"SELECT count(*) FROM items WHERE name LIKE '%--%'":           "[[0]]",
+		"SELECT count(*) FROM items WHERE name = 'a;b' OR name = 'x'": "[[0]]",
+		"SELECT 'it''s; -- fine' AS s":                                "[[\"it's; -- fine\"]]",
+		"-- count\n/* all */ SELECT count(*) FROM items; -- done":     "[[3]]",
+		"SELECT count(*) FROM \"items\" /* ; */":                      "[[3]]",
+	} {
+		args, _ := json.Marshal(DatabaseRequest{Query: query})
+		out, err := dbTool.InvokableRun(ctx, string(args))
+		if err != nil || !strings.Contains(out, want) {
+			t.Errorf("expect %s for %q, but got %s %v", want, query, out, err)
+		}
+	}
+	for _, query := range []string{"SELECT 'a;b'; DELETE FROM items", "SELECT 1 /* x */; -- y\nDROP TABLE items", ";"} {
+		args, _ := json.Marshal(DatabaseRequest{Query: query})
+		if _, err := dbTool.InvokableRun(ctx, string(args)); err == nil {
+			t.Errorf("expect %q to be rejected", query)
+		}
+	}
+
+	out, _ = dbTool.InvokableRun(ctx, `{"query":"SELECT count(*) AS n FROM items"}`)
+	if !strings.Contains(out, "[[3]]") {
+		t.Errorf("expect items to be untouched, but got %s", out)
+	}
+}
+
+func TestSchemaTool(t *testing.T) {
+	db := newTestDB(t)
+	out, err := NewSchemaTool("local_db", db).InvokableRun(context.Background(), `{"table":"items"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp SchemaResponse
+	json.Unmarshal([]byte(out), &resp)
+	if len(resp.Tables) != 1 || len(resp.Tables[0].Columns) != 3 || !resp.Tables[0].Columns[0].PrimaryKey {
+		t.Errorf("expect items with 3 columns and id primary key, but got %s", out)
+	}
+
+	if _, err := NewSchemaTool("local_db", db).InvokableRun(context.Background(), `{"table":"missing"}`); err == nil {
+		t.Errorf("expect error for missing table")
+	}
+}
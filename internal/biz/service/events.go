@@ -0,0 +1,71 @@
+package service
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Event names sent to WebSocket and SSE clients alongside "message" and "end".
+const (
+	EventToolCallStart  = "tool_call_start"
+	EventToolCallResult = "tool_call_result"
+	EventThinking       = "thinking"
+	// EventApprovalRequest asks a WebSocket client to approve a tool call; it answers
+	// with {"type": "approval", "toolCallId": ..., "approved": ..., "reason": ...}.
+	EventApprovalRequest = "approval_request"
+	// EventCancelled ends a WebSocket turn aborted by {"type": "cancel"}.
+	EventCancelled = "cancelled"
+	// EventQueued and EventRejected answer a chat message sent while a turn is
+	// running: it either waits for its turn or is dropped because the queue is full.
+	EventQueued   = "queued"
+	EventRejected = "rejected"
+)
+
+// maxEventResultLen bounds the tool result echoed to clients; the model still sees it in full.
+const maxEventResultLen = 512
+
+// AgentEvent reports the progress of a turn while the model and its tools are working.
+type AgentEvent struct {
+	Event      string `json:"event"`
+	SessionID  string `json:"sessionId,omitempty"`
+	ToolCallID string `json:"toolCallId,omitempty"`
+	ToolName   string `json:"toolName,omitempty"`
+	Arguments  string `json:"arguments,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	LatencyMs  int64  `json:"latencyMs,omitempty"`
+	Content    string `json:"content,omitempty"`
+}
+
+// EventHandler receives AgentEvents. It may be called from several goroutines at
+// once when tools run in parallel.
+type EventHandler func(ev AgentEvent)
+
+// WithEventHandler subscribes h to the progress events of every turn.
+func WithEventHandler(h EventHandler) Option {
+	return func(o *AgentOptions) {
+		o.OnEvent = h
+	}
+}
+
+func toolResultEvent(callID, name, result string, err error, latency time.Duration) AgentEvent {
+	ev := AgentEvent{
+		Event:      EventToolCallResult,
+		ToolCallID: callID,
+		ToolName:   name,
+		LatencyMs:  latency.Milliseconds(),
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	} else {
+		ev.Result = truncate(result, maxEventResultLen)
+	}
+	return ev
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// summaryExtraKey marks the system message that carries a rolling history summary.
+const summaryExtraKey = "history_summary"
+
+// HistoryStrategy compacts a session's history before it is sent to the model. Only
+// the model's input is compacted: the stored history stays complete for listing,
+// export and forks. m is the agent's own model, available to strategies that need
+// to call an LLM.
+type HistoryStrategy interface {
+	Apply(ctx context.Context, m model.BaseChatModel, history []*schema.Message) ([]*schema.Message, error)
+}
+
+// WithHistoryStrategy sets how the agent bounds its conversation history.
+func WithHistoryStrategy(strategy HistoryStrategy) Option {
+	return func(o *AgentOptions) {
+		o.History = strategy
+	}
+}
+
+// LastNHistory keeps the most recent N messages.
+type LastNHistory struct {
+	N int
+}
+
+func (s LastNHistory) Apply(ctx context.Context, m model.BaseChatModel, history []*schema.Message) ([]*schema.Message, error) {
+	if s.N <= 0 || len(history) <= s.N {
+		return history, nil
+	}
+	return history[turnStart(history, len(history)-s.N):], nil
+}
+
+// TokenBudgetHistory keeps the most recent messages whose estimated size fits in
+// MaxTokens. An assistant tool call is kept or dropped together with its results,
+// and the latest message is always kept.
+type TokenBudgetHistory struct {
+	MaxTokens int
+}
+
+func (s TokenBudgetHistory) Apply(ctx context.Context, m model.BaseChatModel, history []*schema.Message) ([]*schema.Message, error) {
+	if s.MaxTokens <= 0 || len(history) == 0 {
+		return history, nil
+	}
+	start, used := len(history), 0
+	for start > 0 {
+		// 工具结果必须与发起调用的 assistant 消息一起保留
+		unit := start - 1
+		for unit > 0 && history[unit].Role == schema.Tool {
+			unit--
+		}
+		cost := EstimateTokens(history[unit:start])
+		if used+cost > s.MaxTokens && start < len(history) {
+			break
+		}
+		used += cost
+		start = unit
+	}
+	return history[start:], nil
+}
+
+// SummaryHistory folds everything but the last KeepLast messages into an LLM-written
+// summary once the history grows past MaxMessages. The summary is a system message
+// at the head of the compacted history; the agent stores it with the session and
+// hands it back on later turns, where it is rolled forward.
+type SummaryHistory struct {
+	MaxMessages int
+	KeepLast    int
+	// Model writes the summary; the agent's own model is used when nil.
+	Model model.BaseChatModel
+}
+
+func (s SummaryHistory) Apply(ctx context.Context, m model.BaseChatModel, history []*schema.Message) ([]*schema.Message, error) {
+	if s.MaxMessages <= 0 || len(history) <= s.MaxMessages {
+		return history, nil
+	}
+	cut := turnStart(history, len(history)-max(s.KeepLast, 1))
+	if cut == 0 {
+		return history, nil
+	}
+	if s.Model != nil {
+		m = s.Model
+	}
+
+	var transcript strings.Builder
+	for _, msg := range history[:cut] {
+		if isHistorySummary(msg) {
+			fmt.Fprintf(&transcript, "[此前摘要] %s\n", msg.Content)
+			continue
+		}
+		fmt.Fprintf(&transcript, "[%s] %s\n", msg.Role, messageText(msg))
+	}
+	resp, err := m.Generate(ctx, []*schema.Message{
+		schema.SystemMessage("请将以下对话压缩为简洁的摘要，保留关键事实、用户偏好、工具查询结果和尚未完成的任务，只输出摘要本身。"),
+		schema.UserMessage(transcript.String()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize history: %v", err)
+	}
+
+	summary := schema.SystemMessage("以下是之前对话的摘要：\n" + resp.Content)
+	summary.Extra = map[string]any{summaryExtraKey: true}
+	return append([]*schema.Message{summary}, history[cut:]...), nil
+}
+
+// HistorySummary is a rolling summary written by SummaryHistory, standing in for the
+// first Covers messages of a session's history.
+type HistorySummary struct {
+	Message *schema.Message `json:"message"`
+	Covers  int             `json:"covers"`
+}
+
+func isHistorySummary(msg *schema.Message) bool {
+	v, _ := msg.Extra[summaryExtraKey].(bool)
+	return v
+}
+
+// turnStart moves i forward past tool results so a kept window never begins with
+// results whose tool call was dropped.
+func turnStart(history []*schema.Message, i int) int {
+	for i < len(history) && history[i].Role == schema.Tool {
+		i++
+	}
+	return i
+}
+
+func messageText(msg *schema.Message) string {
+	text := userText(msg)
+	for _, tc := range msg.ToolCalls {
+		text += fmt.Sprintf(" <调用 %s %s>", tc.Function.Name, tc.Function.Arguments)
+	}
+	return text
+}
+
+// EstimateTokens approximates the prompt size of msgs without a tokenizer: about four
+// ASCII characters or one CJK character per token, plus a per-message overhead.
+func EstimateTokens(msgs []*schema.Message) int {
+	total := 0
+	for _, msg := range msgs {
+		text := messageText(msg)
+		ascii := 0
+		for i := 0; i < len(text); i++ {
+			if text[i] < utf8.RuneSelf {
+				ascii++
+			}
+		}
+		total += 4 + ascii/4 + utf8.RuneCountInString(text) - ascii
+	}
+	return total
+}
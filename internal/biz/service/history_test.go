@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// conversation builds user, assistant(tool call), tool, assistant, user.
+func conversation() []*schema.Message {
+	return []*schema.Message{
+		schema.UserMessage("天气怎么样"),
+		toolCalls("get_weather"),
+		schema.ToolMessage(strings.Repeat("sunny ", 50), "call-get_weather"),
+		schema.AssistantMessage("晴天", nil),
+		schema.UserMessage("谢谢"),
+	}
+}
+
+func TestLastNHistory(t *testing.T) {
+	// the last 3 messages start with an orphaned tool result, which must be dropped
+	kept, _ := LastNHistory{N: 3}.Apply(context.Background(), nil, conversation())
+	if len(kept) != 2 || kept[0].Content != "晴天" {
+		t.Errorf("expect [晴天 谢谢], but got %v", kept)
+	}
+}
+
+func TestTokenBudgetHistory(t *testing.T) {
+	history := conversation()
+	// enough for the last two messages but not the large tool result
+	kept, _ := TokenBudgetHistory{MaxTokens: 20}.Apply(context.Background(), nil, history)
+	if len(kept) != 2 {
+		t.Errorf("expect %d messages, but got %d", 2, len(kept))
+	}
+
+	// the tool result fits but its call does not: both are dropped together
+	budget := EstimateTokens(history[2:])
+	kept, _ = TokenBudgetHistory{MaxTokens: budget}.Apply(context.Background(), nil, history)
+	if len(kept) != 2 {
+		t.Errorf("expect %d messages, but got %d", 2, len(kept))
+	}
+
+	kept, _ = TokenBudgetHistory{MaxTokens: EstimateTokens(history)}.Apply(context.Background(), nil, history)
+	if len(kept) != len(history) {
+		t.Errorf("expect %d messages, but got %d", len(history), len(kept))
+	}
+}
+
+func TestSummaryHistory(t *testing.T) {
+	m := &scriptedModel{responses: []*schema.Message{
+		schema.AssistantMessage("第一次摘要", nil),
+		schema.AssistantMessage("第二次摘要", nil),
+	}}
+	strategy := SummaryHistory{MaxMessages: 4, KeepLast: 2}
+
+	kept, err := strategy.Apply(context.Background(), m, conversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 3 || !isHistorySummary(kept[0]) || !strings.Contains(kept[0].Content, "第一次摘要") {
+		t.Fatalf("expect summary followed by 2 messages, but got %v", kept)
+	}
+
+	// below the threshold nothing changes and the model is not called
+	same, _ := strategy.Apply(context.Background(), m, kept)
+	if len(same) != 3 || m.calls != 1 {
+		t.Errorf("expect no compaction, but got %d messages and %d calls", len(same), m.calls)
+	}
+
+	kept = append(kept, schema.AssistantMessage("不客气", nil), schema.UserMessage("再见"))
+	rolled, _ := strategy.Apply(context.Background(), m, kept)
+	if len(rolled) != 3 || !strings.Contains(rolled[0].Content, "第二次摘要") {
+		t.Errorf("expect rolled summary, but got %v", rolled)
+	}
+}
+
+// windowModel answers "ok" and records the size of each input it is sent.
+type windowModel struct {
+	scriptedModel
+	inputs []int
+}
+
+func (m *windowModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.inputs = append(m.inputs, len(input))
+	if len(input) > 0 && input[0].Role == schema.System && !isHistorySummary(input[0]) {
+		return schema.AssistantMessage("摘要", nil), nil
+	}
+	return schema.AssistantMessage("ok", nil), nil
+}
+
+func TestModelAgent_HistoryStrategyKeepsStoredHistory(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore(0, 0)
+	m := &windowModel{}
+	a, err := newModelAgent("windowed", ctx, m, &AgentOptions{Store: store, History: LastNHistory{N: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"一", "二", "三"} {
+		if _, err := a.Chat(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.inputs[2] != 1 {
+		t.Errorf("expect the model to get %d message, but got %d", 1, m.inputs[2])
+	}
+	if sess := mustLoadFrom(t, store, "windowed"); len(sess.History) != 6 {
+		t.Errorf("expect the full history of %d messages stored, but got %d", 6, len(sess.History))
+	}
+
+	// a summary is stored next to the history and reused on the next turn
+	m.inputs = nil
+	b, _ := newModelAgent("windowed", ctx, m, &AgentOptions{Store: store, History: SummaryHistory{MaxMessages: 4, KeepLast: 1}})
+	b.Chat(ctx, "四")
+	sess := mustLoadFrom(t, store, "windowed")
+	if len(sess.History) != 8 || sess.Summary == nil || sess.Summary.Covers != 6 {
+		t.Fatalf("expect 8 messages and a summary of the first 6, but got %d and %+v", len(sess.History), sess.Summary)
+	}
+	// summary call, then summary + "四"
+	if len(m.inputs) != 2 || m.inputs[1] != 2 {
+		t.Errorf("expect the summary and the last message sent, but got inputs %v", m.inputs)
+	}
+	m.inputs = nil
+	b.Chat(ctx, "五")
+	// summary + "四" + "ok" + "五" stays under the threshold: no new summary
+	if len(m.inputs) != 1 || m.inputs[0] != 4 {
+		t.Errorf("expect the stored summary reused, but got inputs %v", m.inputs)
+	}
+}
+
+func mustLoadFrom(t *testing.T, store SessionStore, id string) *Session {
+	t.Helper()
+	sess, err := store.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// HTTPParam declares one typed argument of an http tool. The tool's JSON schema is
+// generated from its params, and the model's arguments are validated against it
+// before the URL template is rendered.
+type HTTPParam struct {
+	Name        string
+	Type        string // string, number, integer or boolean
+	Description string
+	Required    bool
+	Minimum     *float64
+	Maximum     *float64
+	Enum        []any
+	// Default is used when the model omits the argument.
+	Default any
+}
+
+var paramTypes = map[string]bool{"string": true, "number": true, "integer": true, "boolean": true}
+
+// paramsSchema builds the JSON schema of an object with the given params.
+func paramsSchema(params []HTTPParam) (map[string]any, error) {
+	properties := make(map[string]any, len(params))
+	required := []any{}
+	for _, p := range params {
+		if p.Name == "" {
+			return nil, fmt.Errorf("param name is required")
+		}
+		if _, ok := properties[p.Name]; ok {
+			return nil, fmt.Errorf("duplicate param %s", p.Name)
+		}
+		if !paramTypes[p.Type] {
+			return nil, fmt.Errorf("param %s: unknown type %q", p.Name, p.Type)
+		}
+		prop := map[string]any{"type": p.Type}
+		if p.Description != "" {
+			prop["description"] = p.Description
+		}
+		if p.Minimum != nil {
+			prop["minimum"] = *p.Minimum
+		}
+		if p.Maximum != nil {
+			prop["maximum"] = *p.Maximum
+		}
+		if len(p.Enum) > 0 {
+			prop["enum"] = p.Enum
+		}
+		if p.Default != nil {
+			prop["default"] = p.Default
+		}
+		properties[p.Name] = prop
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+// prepareArgs validates args against the schema, then fills omitted properties with
+// their default, or "" so templates render nothing and can test them with {{with}}.
+func prepareArgs(schema map[string]any, args map[string]any) error {
+	if err := validateValue(schema, args, "arguments"); err != nil {
+		return err
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for name, p := range properties {
+		if args[name] != nil {
+			continue
+		}
+		prop, _ := p.(map[string]any)
+		if def, ok := prop["default"]; ok {
+			args[name] = def
+		} else {
+			args[name] = ""
+		}
+	}
+	return nil
+}
+
+// validateValue checks v, as decoded by encoding/json, against the subset of JSON
+// schema that tool parameters and structured answers use: type, enum, anyOf/oneOf,
+// ranges, lengths, pattern, required, properties, additionalProperties and items.
+func validateValue(schema map[string]any, v any, path string) error {
+	if v == nil {
+		// null 视为未传，由 required 检查
+		return nil
+	}
+	if err := checkTypes(schema["type"], v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		if _, found := indexOf(enum, v); !found {
+			return fmt.Errorf("%s: must be one of %v", path, enum)
+		}
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		alternatives, ok := schema[key].([]any)
+		if !ok {
+			continue
+		}
+		var errs []string
+		for _, alt := range alternatives {
+			err := validateSubschema(alt, v, path)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if errs != nil {
+			return fmt.Errorf("%s: must match one of the alternatives (%s)", path, strings.Join(errs, "; "))
+		}
+	}
+
+	switch val := v.(type) {
+	case float64:
+		if min, ok := toFloat(schema["minimum"]); ok && val < min {
+			return fmt.Errorf("%s: must be >= %v", path, min)
+		}
+		if max, ok := toFloat(schema["maximum"]); ok && val > max {
+			return fmt.Errorf("%s: must be <= %v", path, max)
+		}
+		if min, ok := toFloat(schema["exclusiveMinimum"]); ok && val <= min {
+			return fmt.Errorf("%s: must be > %v", path, min)
+		}
+		if max, ok := toFloat(schema["exclusiveMaximum"]); ok && val >= max {
+			return fmt.Errorf("%s: must be < %v", path, max)
+		}
+	case string:
+		n := float64(utf8.RuneCountInString(val))
+		if min, ok := toFloat(schema["minLength"]); ok && n < min {
+			return fmt.Errorf("%s: must be at least %v characters", path, min)
+		}
+		if max, ok := toFloat(schema["maxLength"]); ok && n > max {
+			return fmt.Errorf("%s: must be at most %v characters", path, max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern: %v", path, err)
+			}
+			if !re.MatchString(val) {
+				return fmt.Errorf("%s: must match %s", path, pattern)
+			}
+		}
+	case map[string]any:
+		required, _ := schema["required"].([]any)
+		for _, r := range required {
+			name, _ := r.(string)
+			if val[name] == nil {
+				return fmt.Errorf("%s: %s is required", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(val)) {
+			prop, ok := properties[name]
+			if !ok {
+				prop, ok = schema["additionalProperties"]
+				if ok && prop == false {
+					return fmt.Errorf("%s: unknown property %s", path, name)
+				}
+			}
+			if err := validateSubschema(prop, val[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []any:
+		n := float64(len(val))
+		if min, ok := toFloat(schema["minItems"]); ok && n < min {
+			return fmt.Errorf("%s: must have at least %v items", path, min)
+		}
+		if max, ok := toFloat(schema["maxItems"]); ok && n > max {
+			return fmt.Errorf("%s: must have at most %v items", path, max)
+		}
+		for i, item := range val {
+			if err := validateSubschema(schema["items"], item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateSubschema validates v against a nested schema, which may also be a
+// boolean schema or absent.
+func validateSubschema(schema any, v any, path string) error {
+	switch s := schema.(type) {
+	case map[string]any:
+		return validateValue(s, v, path)
+	case bool:
+		if !s && v != nil {
+			return fmt.Errorf("%s: not allowed", path)
+		}
+	}
+	return nil
+}
+
+// checkTypes accepts a single type or a list of alternative types.
+func checkTypes(types any, v any) error {
+	switch t := types.(type) {
+	case string:
+		return checkType(t, v)
+	case []any:
+		var names []string
+		for _, item := range t {
+			name, _ := item.(string)
+			if checkType(name, v) == nil {
+				return nil
+			}
+			names = append(names, name)
+		}
+		return fmt.Errorf("must be %s, got %v", strings.Join(names, " or "), v)
+	}
+	return nil
+}
+
+func checkType(typ string, v any) error {
+	ok := false
+	switch typ {
+	case "string":
+		_, ok = v.(string)
+	case "number":
+		_, ok = v.(float64)
+	case "integer":
+		f, isNum := v.(float64)
+		ok = isNum && f == math.Trunc(f)
+	case "boolean":
+		_, ok = v.(bool)
+	case "object":
+		_, ok = v.(map[string]any)
+	case "array":
+		_, ok = v.([]any)
+	case "null":
+		ok = v == nil
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("must be %s, got %v", typ, v)
+	}
+	return nil
+}
+
+// toFloat accepts the numeric types produced by both JSON and YAML decoding.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// indexOf finds v in list, comparing numbers by value.
+func indexOf(list []any, v any) (int, bool) {
+	for i, item := range list {
+		if a, ok := toFloat(item); ok {
+			if b, ok := toFloat(v); ok && a == b {
+				return i, true
+			}
+			continue
+		}
+		if item == v {
+			return i, true
+		}
+	}
+	return -1, false
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+var weatherParams = []HTTPParam{
+	{Name: "latitude", Type: "number", Required: true, Minimum: ptr(-90), Maximum: ptr(90)},
+	{Name: "longitude", Type: "number", Required: true, Minimum: ptr(-180), Maximum: ptr(180)},
+	{Name: "unit", Type: "string", Enum: []any{"celsius", "fahrenheit"}, Default: "celsius"},
+	{Name: "days", Type: "integer"},
+}
+
+func TestPrepareArgs(t *testing.T) {
+	s, err := paramsSchema(weatherParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		`{"latitude":31.2,"longitude":121.5}`:                    "",
+		`{"latitude":91,"longitude":121.5}`:                      "latitude: must be <= 90",
+		`{"latitude":"31.2","longitude":121.5}`:                  "latitude: must be number",
+		`{"longitude":121.5}`:                                    "latitude is required",
+		`{"latitude":1,"longitude":2,"unit":"kelvin"}`:           "unit: must be one of",
+		`{"latitude":1,"longitude":2,"days":1.5}`:                "days: must be integer",
+		`{"latitude":1,"longitude":2,"url":"http://10.0.0.1/x"}`: "unknown property url",
+	}
+	for input, want := range cases {
+		args := map[string]any{}
+		if err := json.Unmarshal([]byte(input), &args); err != nil {
+			t.Fatal(err)
+		}
+		err := prepareArgs(s, args)
+		if want == "" && err != nil || want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("expect %q for %s, but got %v", want, input, err)
+		}
+	}
+
+	args := map[string]any{"latitude": 1.0, "longitude": 2.0}
+	prepareArgs(s, args)
+	if args["unit"] != "celsius" || args["days"] != "" {
+		t.Errorf("expect defaults filled, but got %v", args)
+	}
+
+	if _, err := paramsSchema([]HTTPParam{{Name: "x", Type: "date"}}); err == nil {
+		t.Errorf("expect error for unknown type")
+	}
+}
+
+func TestHTTPTool_TypedParams(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"temperature":20}`))
+	}))
+	defer srv.Close()
+
+	weather, err := NewHTTPTool(ToolConfig{
+		Name:                 "get_weather",
+		Type:                 ToolTypeHTTP,
+		URL:                  srv.URL + "/forecast?latitude={{.latitude}}&longitude={{.longitude}}&unit={{.unit}}{{with .days}}&days={{.}}{{end}}",
+		Params:               weatherParams,
+		AllowPrivateNetworks: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := weather.Info(context.Background())
+	js, _ := info.ParamsOneOf.ToJSONSchema()
+	if js.Properties.Len() != 4 || len(js.Required) != 2 {
+		t.Errorf("expect schema with 4 properties and 2 required, but got %+v", js)
+	}
+
+	if _, err := weather.InvokableRun(context.Background(), `{"latitude":123,"longitude":121.47}`); err == nil {
+		t.Errorf("expect validation error")
+	}
+	if len(requests) != 0 {
+		t.Errorf("expect no request for invalid arguments, but got %v", requests)
+	}
+
+	if _, err := weather.InvokableRun(context.Background(), `{"latitude":31.23,"longitude":121.47,"days":3}`); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || requests[0] != "latitude=31.23&longitude=121.47&unit=celsius&days=3" {
+		t.Errorf("expect rendered query, but got %v", requests)
+	}
+}
+
+func TestHTTPTool_InvalidArgumentsResult(t *testing.T) {
+	weather, err := NewHTTPTool(ToolConfig{
+		Name:   "get_weather",
+		Type:   ToolTypeHTTP,
+		URL:    "http://127.0.0.1:1/forecast?latitude={{.latitude}}&longitude={{.longitude}}",
+		Params: weatherParams,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop := NewToolLoop(map[string]tool.InvokableTool{"get_weather": weather}, &AgentOptions{})
+	results := loop.RunTools(context.Background(), []schema.ToolCall{{
+		ID:       "call-1",
+		Function: schema.FunctionCall{Name: "get_weather", Arguments: `{"latitude":91,"longitude":121.5}`},
+	}})
+
+	// 参数错误交给模型修正后重试，而不是让它放弃
+	res := results[0].Content
+	if !strings.Contains(res, "latitude: must be <= 90") || !strings.Contains(res, "重新调用") || strings.Contains(res, "不要重试") {
+		t.Errorf("expect the validation error with a request to retry, but got %q", res)
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	var schema map[string]any
+	json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"id":    {"type": ["integer", "string"]},
+			"level": {"enum": [1, 2]},
+			"tags":  {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string"}},
+			"extra": {"type": "object", "additionalProperties": {"type": "number"}},
+			"any":   true,
+			"never": false,
+			"value": {"anyOf": [{"type": "boolean"}, {"type": "number", "minimum": 0}]}
+		},
+		"additionalProperties": false
+	}`), &schema)
+
+	cases := map[string]string{
+		`{"id":1,"level":1.0,"tags":["a"],"extra":{"x":1},"any":[1],"value":true}`: "",
+		`{"id":"a","value":3}`:         "",
+		`{"id":true}`:                  "$.id: must be integer or string",
+		`{"level":3}`:                  "$.level: must be one of",
+		`{"tags":[]}`:                  "$.tags: must have at least 1 items",
+		`{"tags":["a","b","c"]}`:       "$.tags: must have at most 2 items",
+		`{"extra":{"x":"1"}}`:          "$.extra.x: must be number",
+		`{"never":1}`:                  "$.never: not allowed",
+		`{"value":-1}`:                 "$.value: must match one of the alternatives",
+		`{"other":1}`:                  "$: unknown property other",
+		`{"tags":["a"],"pattern":"x"}`: "$: unknown property pattern",
+	}
+	for input, want := range cases {
+		var v any
+		if err := json.Unmarshal([]byte(input), &v); err != nil {
+			t.Fatal(err)
+		}
+		err := validateValue(schema, v, "$")
+		if want == "" && err != nil || want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("expect %q for %s, but got %v", want, input, err)
+		}
+	}
+
+	err := validateValue(map[string]any{"pattern": "("}, "x", "$")
+	if err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("expect an invalid pattern error, but got %v", err)
+	}
+}
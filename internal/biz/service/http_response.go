@@ -0,0 +1,112 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmespath/go-jmespath"
+	"golang.org/x/net/html"
+)
+
+// formatResponse turns an HTTP tool's response body into text for the model.
+func formatResponse(contentType string, data []byte, extract *jmespath.JMESPath) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var body interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return "", fmt.Errorf("invalid JSON response: %v", err)
+		}
+		if extract != nil {
+			var err error
+			if body, err = extract.Search(body); err != nil {
+				return "", fmt.Errorf("failed to extract from response: %v", err)
+			}
+		}
+		bodyBytes, _ := json.Marshal(body)
+		return string(bodyBytes), nil
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return htmlToText(data)
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml"):
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("response is not valid UTF-8 text")
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return "", fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
+// htmlSkipped elements never contain readable text.
+var htmlSkipped = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "head": true,
+}
+
+// htmlBlocks start on a new line.
+var htmlBlocks = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true,
+	"article": true, "header": true, "footer": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "pre": true, "blockquote": true,
+	"table": true, "ul": true, "ol": true, "title": true,
+}
+
+// htmlToText extracts the readable text of an HTML page: the title and visible
+// text, one block per line with whitespace collapsed.
+func htmlToText(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid HTML response: %v", err)
+	}
+
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if text := strings.Join(strings.Fields(line.String()), " "); text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.Data == "title" && n.FirstChild != nil {
+				flush()
+				line.WriteString(n.FirstChild.Data)
+				flush()
+				return
+			}
+			if htmlSkipped[n.Data] {
+				// head 中只取 title
+				if n.Data == "head" {
+					for c := n.FirstChild; c != nil; c = c.NextSibling {
+						if c.Type == html.ElementNode && c.Data == "title" {
+							walk(c)
+						}
+					}
+				}
+				return
+			}
+			if htmlBlocks[n.Data] {
+				flush()
+			}
+		}
+		if n.Type == html.TextNode {
+			line.WriteString(n.Data)
+			line.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && htmlBlocks[n.Data] {
+			flush()
+		}
+	}
+	walk(doc)
+	flush()
+	return strings.Join(lines, "\n"), nil
+}
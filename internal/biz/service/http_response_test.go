@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+func TestFormatResponse(t *testing.T) {
+	page := `<html><head><title>天气预报</title><style>p{}</style><script>var x = 1;</script></head>
+<body><h1>上海</h1><p>晴，   25°C</p><ul><li>湿度 60%</li><li>东风 3 级</li></ul></body></html>`
+	out, err := formatResponse("text/html; charset=utf-8", []byte(page), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "天气预报\n上海\n晴， 25°C\n湿度 60%\n东风 3 级"; out != expect {
+		t.Errorf("expect %q, but got %q", expect, out)
+	}
+
+	extract := jmespath.MustCompile("current.temperature")
+	out, _ = formatResponse("application/json", []byte(`{"current": {"temperature": 25, "wind": 3}}`), extract)
+	if out != "25" {
+		t.Errorf("expect %s, but got %s", "25", out)
+	}
+
+	out, _ = formatResponse("text/plain", []byte("  hello \n"), nil)
+	if out != "hello" {
+		t.Errorf("expect %s, but got %s", "hello", out)
+	}
+
+	if _, err := formatResponse("application/octet-stream", []byte{0, 1}, nil); err == nil {
+		t.Errorf("expect error for binary response")
+	}
+}
+
+func TestHTTPTool_Post(t *testing.T) {
+	var method, contentType string
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, contentType = r.Method, r.Header.Get("Content-Type")
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"id": 7, "title": "x"}, "meta": {}}`))
+	}))
+	defer srv.Close()
+
+	create, err := NewHTTPTool(ToolConfig{
+		Name:   "create_ticket",
+		Type:   ToolTypeHTTP,
+		Method: "post",
+		URL:    srv.URL + "/tickets",
+		Body:   `{"title": {{json .title}}, "priority": {{.priority}}}`,
+		Params: []HTTPParam{
+			{Name: "title", Type: "string", Required: true},
+			{Name: "priority", Type: "integer", Default: 3},
+		},
+		Extract:              "data.id",
+		AllowPrivateNetworks: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := create.InvokableRun(context.Background(), `{"title":"打印机\"卡纸\""}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "7" || method != http.MethodPost || contentType != "application/json" {
+		t.Errorf("expect id 7 from a JSON POST, but got %s %s %s", out, method, contentType)
+	}
+	if body["title"] != `打印机"卡纸"` || body["priority"] != float64(3) {
+		t.Errorf("expect rendered body, but got %v", body)
+	}
+
+	for _, cfg := range []ToolConfig{
+		{Name: "get_body", Method: "GET", Body: "{}"},
+		{Name: "delete", Method: "DELETE"},
+		{Name: "bad_extract", Extract: "data.["},
+	} {
+		if _, err := NewHTTPTool(cfg); err == nil || !strings.Contains(err.Error(), cfg.Name) {
+			t.Errorf("expect error for %s, but got %v", cfg.Name, err)
+		}
+	}
+}
@@ -12,7 +12,11 @@ type Handler interface {
 }
 
 type Agent interface {
-	Chat(ctx context.Context, msg string) (string, error)
-	ChatStream(ctx context.Context, msg string) (*schema.StreamReader[*schema.Message], error)
+	Chat(ctx context.Context, msg string, opts ...CallOption) (string, error)
+	ChatStream(ctx context.Context, msg string, opts ...CallOption) (*schema.StreamReader[*schema.Message], error)
+	// ChatMessage and ChatMessageStream take a whole user message, such as one with
+	// images built by NewUserMessage.
+	ChatMessage(ctx context.Context, msg *schema.Message, opts ...CallOption) (string, error)
+	ChatMessageStream(ctx context.Context, msg *schema.Message, opts ...CallOption) (*schema.StreamReader[*schema.Message], error)
 	AddHistory(resp *schema.Message)
 }
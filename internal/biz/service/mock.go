@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+var _ Agent = (*Mock)(nil)
+
+func init() {
+	RegisterAgent(MockAgent, AgentFactory{
+		New: func(sessionId string, ctx context.Context, opts *AgentOptions) (Agent, error) {
+			return NewMock(sessionId, ctx, opts)
+		},
+		DefaultModel: func() string { return "mock" },
+		Description:  "离线脚本化模拟 Agent，无需 API Key",
+	})
+}
+
+// MockToolCall is a tool invocation the mock model pretends to ask for.
+type MockToolCall struct {
+	Name      string
+	Arguments string
+}
+
+// MockReply is one scripted assistant turn. The model first asks for ToolCalls, if
+// any, and answers with Content once their results are in; an empty Content answers
+// with the tool results themselves.
+type MockReply struct {
+	Content   string
+	ToolCalls []MockToolCall
+}
+
+// MockScript configures the replies of the mock model. Replies are used in order,
+// one per user message in the conversation it is sent, wrapping around when
+// exhausted; without replies the model echoes the user message.
+type MockScript struct {
+	Replies    []MockReply
+	ChunkSize  int           // runes per streamed chunk, default 4
+	ChunkDelay time.Duration // pause between streamed chunks
+}
+
+// WithMockScript sets the script used by the mock agent.
+func WithMockScript(script *MockScript) Option {
+	return func(o *AgentOptions) {
+		o.Mock = script
+	}
+}
+
+// Mock is a deterministic offline Agent that needs neither an API key nor network.
+// It runs the same conversation loop as the other backends on a scripted model.
+type Mock struct {
+	*modelAgent
+}
+
+func NewMock(sessionId string, ctx context.Context, opts *AgentOptions) (*Mock, error) {
+	m := &mockModel{}
+	if opts.Mock != nil {
+		m.script = *opts.Mock
+	}
+	if m.script.ChunkSize <= 0 {
+		m.script.ChunkSize = 4
+	}
+
+	base, err := newModelAgent(sessionId, ctx, m, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Mock{modelAgent: base}, nil
+}
+
+// mockModel is a model.ToolCallingChatModel that follows a MockScript.
+type mockModel struct {
+	script MockScript
+}
+
+var _ model.ToolCallingChatModel = (*mockModel)(nil)
+
+func (m *mockModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	turn, user, results := mockTurn(input)
+	log.Printf("[Mock] received: %s", userText(user))
+
+	var msg *schema.Message
+	switch {
+	case len(m.script.Replies) == 0:
+		msg = schema.AssistantMessage("[mock] "+userText(user), nil)
+	case results == nil && len(m.script.Replies[turn%len(m.script.Replies)].ToolCalls) > 0:
+		reply := m.script.Replies[turn%len(m.script.Replies)]
+		calls := make([]schema.ToolCall, 0, len(reply.ToolCalls))
+		for i, tc := range reply.ToolCalls {
+			calls = append(calls, schema.ToolCall{
+				ID:       fmt.Sprintf("mock-call-%d-%d", turn, i),
+				Type:     "function",
+				Function: schema.FunctionCall{Name: tc.Name, Arguments: tc.Arguments},
+			})
+		}
+		msg = schema.AssistantMessage("", calls)
+	default:
+		content := m.script.Replies[turn%len(m.script.Replies)].Content
+		if content == "" {
+			content = strings.Join(results, "")
+		}
+		msg = schema.AssistantMessage(content, nil)
+	}
+
+	// 按真实模型的方式附上估算的用量
+	prompt, completion := EstimateTokens(input), EstimateTokens([]*schema.Message{msg})
+	msg.ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}}
+	return msg, nil
+}
+
+// Stream sends a tool call in one chunk and an answer in chunks of ChunkSize runes,
+// with the usage on the last one.
+func (m *mockModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.ToolCalls) > 0 {
+		return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+	}
+
+	out, w := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer w.Close()
+		runes := []rune(msg.Content)
+		for i := 0; i < len(runes); i += m.script.ChunkSize {
+			if i > 0 && m.script.ChunkDelay > 0 {
+				select {
+				case <-ctx.Done():
+					w.Send(nil, ctx.Err())
+					return
+				case <-time.After(m.script.ChunkDelay):
+				}
+			}
+			end := min(i+m.script.ChunkSize, len(runes))
+			chunk := schema.AssistantMessage(string(runes[i:end]), nil)
+			if end == len(runes) {
+				chunk.ResponseMeta = msg.ResponseMeta
+			}
+			if closed := w.Send(chunk, nil); closed {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// WithTools returns the model itself: the script decides which tools are called.
+func (m *mockModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// mockTurn finds the current turn of a conversation: its index among the user
+// messages, the user message, and the tool results produced since, nil before the
+// first tool round.
+func mockTurn(input []*schema.Message) (int, *schema.Message, []string) {
+	turn, last := -1, -1
+	for i, msg := range input {
+		if msg.Role == schema.User {
+			turn, last = turn+1, i
+		}
+	}
+	if last < 0 {
+		return 0, schema.UserMessage(""), nil
+	}
+	var results []string
+	for _, msg := range input[last+1:] {
+		if msg.Role == schema.Tool {
+			results = append(results, msg.Content)
+		}
+	}
+	return turn, input[last], results
+}
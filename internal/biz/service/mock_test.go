@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+type echoInput struct {
+	Text string `json:"text"`
+}
+
+func TestMock_ScriptedToolCall(t *testing.T) {
+	echo := utils.NewTool[echoInput, string](
+		&schema.ToolInfo{Name: "echo", Desc: "echo"},
+		func(ctx context.Context, in echoInput) (string, error) {
+			return "echo:" + in.Text, nil
+		},
+	)
+	ctx := context.Background()
+	store := NewMemorySessionStore(0, 0)
+	agent, err := NewAgent(MockAgent, "mock-tool", ctx,
+		WithSessionStore(store),
+		WithTools(echo),
+		WithMockScript(&MockScript{Replies: []MockReply{
+			{ToolCalls: []MockToolCall{{Name: "echo", Arguments: `{"text":"hi"}`}}},
+			{Content: "second"},
+		}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := agent.Chat(ctx, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "echo:hi" {
+		t.Errorf("expect %q, but got %q", "echo:hi", res)
+	}
+	res, _ = agent.Chat(ctx, "again")
+	if res != "second" {
+		t.Errorf("expect %q, but got %q", "second", res)
+	}
+
+	sess, err := store.Load("mock-tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// user, assistant(tool call), tool, assistant, user, assistant
+	if len(sess.History) != 6 {
+		t.Errorf("expect %d messages, but got %d", 6, len(sess.History))
+	}
+}
+
+func TestMock_ChatStream(t *testing.T) {
+	ctx := context.Background()
+	agent, err := NewAgent(MockAgent, "mock-stream", ctx,
+		WithSessionStore(NewMemorySessionStore(0, 0)),
+		WithMockScript(&MockScript{Replies: []MockReply{{Content: "hello world"}}, ChunkSize: 3}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := agent.ChatStream(ctx, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	var chunks []string
+	for {
+		chunk, err := reader.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk.Content)
+	}
+	if len(chunks) != 4 || strings.Join(chunks, "") != "hello world" {
+		t.Errorf("expect 4 chunks of %q, but got %q", "hello world", chunks)
+	}
+}
+
+func TestMock_RunsAgentLoop(t *testing.T) {
+	ctx := context.Background()
+	script := &MockScript{Replies: []MockReply{{ToolCalls: []MockToolCall{{Name: "echo", Arguments: `{}`}}}}}
+	agent, err := NewAgent(MockAgent, "mock-loop", ctx,
+		WithSessionStore(NewMemorySessionStore(0, 0)),
+		WithMockScript(script),
+		WithMaxIterations(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agent.Chat(ctx, "hi"); !errors.Is(err, ErrMaxIterations) {
+		t.Errorf("expect %v, but got %v", ErrMaxIterations, err)
+	}
+
+}
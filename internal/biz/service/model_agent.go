@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// modelAgent implements the Agent conversation loop on top of any eino chat model.
+// Backends embed it and only differ in how the model is constructed. The model is
+// never changed: tools are bound to copies of it, so calls with different tool sets
+// do not affect each other.
+type modelAgent struct {
+	sessionId string
+	model     model.ToolCallingChatModel
+	bound     model.ToolCallingChatModel // model with the agent's own tools
+	history   []*schema.Message
+	window    []*schema.Message // history as sent to the model this turn
+	summary   *HistorySummary
+	loop      *ToolLoop
+	store     SessionStore
+	strategy  HistoryStrategy
+	system    *schema.Message
+	wait      time.Duration
+	tools     *toolSet // the agent's own tools
+	// jsonMode requests an answer of a JSON schema: it returns the model to use in
+	// place of model, nil to keep it, and options for every model call. It is nil
+	// for backends without a JSON mode.
+	jsonMode func(js *jsonschema.Schema) (model.ToolCallingChatModel, []model.Option, error)
+	*usageMeter
+}
+
+// newModelAgent loads the session history and binds the agent's tools to a copy of m.
+func newModelAgent(sessionId string, ctx context.Context, m model.ToolCallingChatModel, opts *AgentOptions) (*modelAgent, error) {
+	store := opts.Store
+	if store == nil {
+		store = defaultSessionStore
+	}
+	sess, err := loadStoredSession(store, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	system, err := systemPrompt(ctx, sessionId, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tools := newToolSet(ctx, opts.Tools)
+	bound, err := tools.bind(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind tools: %v", err)
+	}
+
+	a := &modelAgent{
+		sessionId:  sessionId,
+		model:      m,
+		bound:      bound,
+		tools:      tools,
+		history:    sess.History,
+		summary:    sess.Summary,
+		loop:       NewToolLoop(tools.byName, opts),
+		store:      store,
+		strategy:   opts.History,
+		system:     system,
+		wait:       opts.SessionWait,
+		usageMeter: newUsageMeter(opts, sess.Usage),
+	}
+	a.loop.CheckBudget = a.checkBudget
+	return a, nil
+}
+
+func (a *modelAgent) Chat(ctx context.Context, msg string, opts ...CallOption) (string, error) {
+	return a.ChatMessage(ctx, schema.UserMessage(msg), opts...)
+}
+
+func (a *modelAgent) ChatStream(ctx context.Context, msg string, opts ...CallOption) (*schema.StreamReader[*schema.Message], error) {
+	return a.ChatMessageStream(ctx, schema.UserMessage(msg), opts...)
+}
+
+func (a *modelAgent) ChatMessage(ctx context.Context, msg *schema.Message, opts ...CallOption) (string, error) {
+	log.Printf("[Chat] received: %s", userText(msg))
+	m, loop, err := a.callModel(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	release, err := a.beginTurn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	start := len(a.history)
+	a.history = append(a.history, msg)
+	a.compactHistory(ctx)
+
+	produced, err := loop.Generate(ctx, m, a.input())
+	a.endTurn(start, produced, err)
+	if err != nil {
+		return "", err
+	}
+	return produced[len(produced)-1].Content, nil
+}
+
+func (a *modelAgent) ChatMessageStream(ctx context.Context, msg *schema.Message, opts ...CallOption) (*schema.StreamReader[*schema.Message], error) {
+	log.Printf("[ChatStream] received: %s", userText(msg))
+	m, loop, err := a.callModel(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	release, err := a.beginTurn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := len(a.history)
+	a.history = append(a.history, msg)
+	a.compactHistory(ctx)
+
+	// 流结束后再把本轮的工具调用和完整回复写入历史，然后结束本轮
+	return loop.Stream(ctx, m, a.input(), func(produced []*schema.Message, err error) {
+		a.endTurn(start, produced, err)
+		release()
+	})
+}
+
+// callModel returns the model and tool loop for one call: the agent's own, or
+// copies offering only the call's tools and requesting the call's response schema.
+func (a *modelAgent) callModel(ctx context.Context, opts []CallOption) (model.BaseChatModel, *ToolLoop, error) {
+	o := applyCallOptions(opts)
+	if !o.toolsSet && (o.ResponseSchema == nil || a.jsonMode == nil) {
+		return a.bound, a.loop, nil
+	}
+
+	base, tools := a.model, a.tools
+	var modelOpts []model.Option
+	if o.ResponseSchema != nil && a.jsonMode != nil {
+		jm, jsonOpts, err := a.jsonMode(o.ResponseSchema)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to request JSON output: %v", err)
+		}
+		if jm != nil {
+			base = jm
+		}
+		modelOpts = jsonOpts
+	}
+	if o.toolsSet {
+		tools = newToolSet(ctx, o.Tools)
+	}
+	m, err := tools.bind(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to bind tools: %v", err)
+	}
+	loop := *a.loop
+	loop.Tools = tools.byName
+	loop.ModelOptions = append(slices.Clip(loop.ModelOptions), modelOpts...)
+	return m, &loop, nil
+}
+
+// beginTurn takes the session's turn lock and reloads the session, which other
+// agents may have changed since the last turn. The returned function ends the turn.
+func (a *modelAgent) beginTurn(ctx context.Context) (func(), error) {
+	release, err := lockSession(ctx, a.sessionId, a.wait)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := loadStoredSession(a.store, a.sessionId)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	a.history, a.summary = sess.History, sess.Summary
+	a.usageMeter.reset(sess.Usage)
+	if err := a.checkBudget(Usage{}); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// input is the conversation sent to the model: the system prompt, which is not
+// stored in the session, followed by the compacted history with the data of its
+// attachments filled in.
+func (a *modelAgent) input() []*schema.Message {
+	window := a.window
+	if window == nil {
+		window = a.history
+	}
+	window = expandUploads(window)
+	if a.system == nil {
+		return window
+	}
+	return append([]*schema.Message{a.system}, window...)
+}
+
+// compactHistory applies the history strategy to what is sent to the model; the
+// stored history stays complete. A summary the strategy writes is kept with the
+// session and stands in for the messages it covers on later turns. A failing
+// strategy (e.g. the summary call erroring) leaves the window uncompacted rather
+// than failing the turn.
+func (a *modelAgent) compactHistory(ctx context.Context) {
+	a.window = a.history
+	if a.summary != nil && a.summary.Covers <= len(a.history) {
+		a.window = append([]*schema.Message{a.summary.Message}, a.history[a.summary.Covers:]...)
+	}
+	if a.strategy == nil {
+		return
+	}
+	window, err := a.strategy.Apply(ctx, a.model, a.window)
+	if err != nil {
+		log.Printf("[Agent] history strategy error: %v", err)
+		return
+	}
+	if len(window) > 0 && isHistorySummary(window[0]) && (a.summary == nil || window[0] != a.summary.Message) {
+		a.summary = &HistorySummary{Message: window[0], Covers: len(a.history) - len(window) + 1}
+	}
+	a.window = window
+}
+
+// endTurn accounts for the usage of the turn whose user message is history[start]
+// and saves the session. A failed turn is not added to the history, but the tokens
+// it spent are still stored so they count against the budget.
+func (a *modelAgent) endTurn(start int, produced []*schema.Message, err error) {
+	usage := turnUsage(produced)
+	a.record(usage)
+	if err != nil {
+		a.history = a.history[:start]
+		if usage.TotalTokens == 0 {
+			return
+		}
+		log.Printf("[Agent] turn of session %s failed after %d tokens: %v", a.sessionId, usage.TotalTokens, err)
+	} else {
+		a.history = append(a.history, produced...)
+	}
+	a.saveSession()
+}
+
+func (a *modelAgent) saveSession() {
+	err := a.store.Save(&Session{ID: a.sessionId, History: a.history, Usage: a.sessionUsage(), Summary: a.summary})
+	if err != nil {
+		log.Printf("[Agent] failed to save session %s: %v", a.sessionId, err)
+	}
+}
+
+func (a *modelAgent) AddHistory(resp *schema.Message) {
+	// 目前在 Chat/ChatStream 内部维护历史
+}
+
+func (a *modelAgent) GetSessionId() string {
+	return a.sessionId
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/eino-contrib/jsonschema"
+)
+
+var _ Agent = (*OpenAI)(nil)
+
+func init() {
+	RegisterAgent(OpenAIAgent, AgentFactory{
+		New: func(sessionId string, ctx context.Context, opts *AgentOptions) (Agent, error) {
+			return NewOpenAI(sessionId, ctx, opts)
+		},
+		DefaultModel: defaultOpenAIModel,
+		Description:  "OpenAI 兼容接口（/v1/chat/completions），可指向 llama.cpp、vLLM 等本地推理服务",
+	})
+}
+
+func defaultOpenAIModel() string {
+	if modelID := os.Getenv("OPENAI_MODEL_ID"); modelID != "" {
+		return modelID
+	}
+	return "gpt-4o-mini"
+}
+
+// OpenAI is an Agent backed by any server speaking the OpenAI chat completions protocol.
+type OpenAI struct {
+	*modelAgent
+}
+
+func NewOpenAI(sessionId string, ctx context.Context, opts *AgentOptions) (*OpenAI, error) {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = os.Getenv("OPENAI_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	modelID := opts.ModelID
+	if modelID == "" {
+		modelID = defaultOpenAIModel()
+	}
+
+	m, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		APIKey:  os.Getenv("OPENAI_API_KEY"),
+		BaseURL: baseURL,
+		Model:   modelID,
+		Timeout: opts.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openai model: %v", err)
+	}
+
+	base, err := newModelAgent(sessionId, ctx, m, opts)
+	if err != nil {
+		return nil, err
+	}
+	base.jsonMode = openAIJSONMode
+	return &OpenAI{modelAgent: base}, nil
+}
+
+// openAIJSONMode requests a JSON answer of js through response_format.
+func openAIJSONMode(js *jsonschema.Schema) (model.ToolCallingChatModel, []model.Option, error) {
+	return nil, []model.Option{openai.WithExtraFields(map[string]any{
+		"response_format": map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "answer",
+				"schema": js,
+			},
+		},
+	})}, nil
+}
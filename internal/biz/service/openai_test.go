@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+// newOpenAIStub serves /chat/completions: it asks for the echo tool on a user
+// turn and answers with the tool result once it is present.
+func newOpenAIStub(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream   bool `json:"stream"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+			return
+		}
+		last := req.Messages[len(req.Messages)-1]
+
+		var deltas []string
+		finish := "tool_calls"
+		if last.Role == "tool" {
+			finish = "stop"
+			deltas = []string{
+				`{"role":"assistant","content":"done "}`,
+				fmt.Sprintf(`{"content":%q}`, last.Content),
+			}
+		} else {
+			deltas = []string{
+				`{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"echo","arguments":"{\"text\":"}}]}`,
+				`{"tool_calls":[{"index":0,"function":{"arguments":"\"hi\"}"}}]}`,
+			}
+		}
+
+		if !req.Stream {
+			var msg map[string]any
+			if last.Role == "tool" {
+				msg = map[string]any{"role": "assistant", "content": "done " + last.Content}
+			} else {
+				msg = map[string]any{"role": "assistant", "tool_calls": []map[string]any{{
+					"id": "call_1", "type": "function",
+					"function": map[string]any{"name": "echo", "arguments": `{"text":"hi"}`},
+				}}}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"id": "chatcmpl-1", "object": "chat.completion", "created": 1, "model": "stub",
+				"choices": []map[string]any{{"index": 0, "message": msg, "finish_reason": finish}},
+				"usage":   map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i, d := range deltas {
+			fr := "null"
+			if i == len(deltas)-1 {
+				fr = fmt.Sprintf("%q", finish)
+			}
+			fmt.Fprintf(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"stub\",\"choices\":[{\"index\":0,\"delta\":%s,\"finish_reason\":%s}]}\n\n", d, fr)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestOpenAI_ToolLoop(t *testing.T) {
+	srv := newOpenAIStub(t)
+	defer srv.Close()
+
+	echo := utils.NewTool[echoInput, string](
+		&schema.ToolInfo{Name: "echo", Desc: "echo"},
+		func(ctx context.Context, in echoInput) (string, error) {
+			return in.Text, nil
+		},
+	)
+	newAgent := func() Agent {
+		agent, err := NewAgent(OpenAIAgent, "openai-test", context.Background(),
+			WithBaseURL(srv.URL+"/v1"),
+			WithModelID("stub"),
+			WithTools(echo),
+			WithSessionStore(NewMemorySessionStore(0, 0)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return agent
+	}
+
+	res, err := newAgent().Chat(context.Background(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "done hi" {
+		t.Errorf("expect %q, but got %q", "done hi", res)
+	}
+
+	reader, err := newAgent().ChatStream(context.Background(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	var streamed string
+	for {
+		chunk, err := reader.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed += chunk.Content
+	}
+	if streamed != "done hi" {
+		t.Errorf("expect %q, but got %q", "done hi", streamed)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// DefaultPersona is used when a request does not name a persona.
+const DefaultPersona = "default"
+
+const defaultLocale = "zh-CN"
+
+// Persona is a named system prompt that requests can select.
+type Persona struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	SystemPrompt string `json:"-"`
+}
+
+var (
+	personasMtx sync.RWMutex
+	personas    = make(map[string]Persona)
+)
+
+// SetPersonas replaces the available personas, typically with those from configs/.
+func SetPersonas(list []Persona) {
+	personasMtx.Lock()
+	defer personasMtx.Unlock()
+	personas = make(map[string]Persona, len(list))
+	for _, p := range list {
+		personas[p.Name] = p
+	}
+}
+
+// ListPersonas returns the available personas sorted by name.
+func ListPersonas() []Persona {
+	personasMtx.RLock()
+	defer personasMtx.RUnlock()
+	list := make([]Persona, 0, len(personas))
+	for _, p := range personas {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func lookupPersona(name string) (Persona, bool) {
+	personasMtx.RLock()
+	defer personasMtx.RUnlock()
+	p, ok := personas[name]
+	return p, ok
+}
+
+// WithSystemPrompt sets a literal system prompt, overriding any persona.
+func WithSystemPrompt(prompt string) Option {
+	return func(o *AgentOptions) {
+		o.SystemPrompt = prompt
+	}
+}
+
+// WithPromptTemplate sets a system prompt rendered as a Go template. Besides vars,
+// it can use {{.session_id}}, {{.date}} and {{.locale}}.
+func WithPromptTemplate(tmpl string, vars map[string]any) Option {
+	return func(o *AgentOptions) {
+		o.PromptTemplate = tmpl
+		o.PromptVars = vars
+	}
+}
+
+// WithPersona selects a configured persona's prompt template.
+func WithPersona(name string) Option {
+	return func(o *AgentOptions) {
+		o.Persona = name
+	}
+}
+
+// WithLocale sets the user locale available to prompt templates.
+func WithLocale(locale string) Option {
+	return func(o *AgentOptions) {
+		o.Locale = locale
+	}
+}
+
+// systemPrompt resolves the system message for a session: an explicit prompt wins
+// over a template, which wins over the requested or default persona. It returns nil
+// when nothing is configured.
+func systemPrompt(ctx context.Context, sessionId string, opts *AgentOptions) (*schema.Message, error) {
+	if opts.SystemPrompt != "" {
+		return schema.SystemMessage(opts.SystemPrompt), nil
+	}
+
+	tmpl := opts.PromptTemplate
+	if tmpl == "" {
+		name := opts.Persona
+		if name == "" {
+			name = DefaultPersona
+		}
+		p, ok := lookupPersona(name)
+		if !ok && opts.Persona != "" {
+			return nil, fmt.Errorf("unknown persona: %s", opts.Persona)
+		}
+		tmpl = p.SystemPrompt
+	}
+	if tmpl == "" {
+		return nil, nil
+	}
+
+	locale := opts.Locale
+	if locale == "" {
+		locale = defaultLocale
+	}
+	vars := map[string]any{
+		"session_id": sessionId,
+		"date":       time.Now().Format("2006-01-02"),
+		"locale":     locale,
+	}
+	for k, v := range opts.PromptVars {
+		vars[k] = v
+	}
+	msgs, err := schema.SystemMessage(tmpl).Format(ctx, vars, schema.GoTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render system prompt: %v", err)
+	}
+	return msgs[0], nil
+}
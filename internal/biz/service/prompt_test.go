@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSystemPrompt(t *testing.T) {
+	SetPersonas([]Persona{
+		{Name: DefaultPersona, SystemPrompt: "default for {{.session_id}}"},
+		{Name: "poet", SystemPrompt: "answer in verse, locale {{.locale}}, mood {{.mood}}"},
+	})
+	defer SetPersonas(nil)
+	ctx := context.Background()
+
+	msg, err := systemPrompt(ctx, "s1", &AgentOptions{})
+	if err != nil || msg.Content != "default for s1" {
+		t.Errorf("expect default persona, but got %v, %v", msg, err)
+	}
+
+	msg, err = systemPrompt(ctx, "s1", &AgentOptions{Persona: "poet", Locale: "en-US", PromptVars: map[string]any{"mood": "calm"}})
+	if err != nil || msg.Content != "answer in verse, locale en-US, mood calm" {
+		t.Errorf("expect rendered poet persona, but got %v, %v", msg, err)
+	}
+
+	msg, _ = systemPrompt(ctx, "s1", &AgentOptions{Persona: "poet", SystemPrompt: "literal {{.locale}}"})
+	if msg.Content != "literal {{.locale}}" {
+		t.Errorf("expect literal prompt to win, but got %q", msg.Content)
+	}
+
+	msg, _ = systemPrompt(ctx, "s1", &AgentOptions{PromptTemplate: "today is {{.date}}"})
+	if !strings.HasPrefix(msg.Content, "today is 20") {
+		t.Errorf("expect rendered date, but got %q", msg.Content)
+	}
+
+	if _, err := systemPrompt(ctx, "s1", &AgentOptions{Persona: "missing"}); err == nil {
+		t.Errorf("expect error for unknown persona")
+	}
+}
+
+func TestSystemPrompt_None(t *testing.T) {
+	SetPersonas(nil)
+	msg, err := systemPrompt(context.Background(), "s1", &AgentOptions{})
+	if err != nil || msg != nil {
+		t.Errorf("expect no system prompt, but got %v, %v", msg, err)
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMaxResponseBytes caps how much of an HTTP tool's response is read.
+	DefaultMaxResponseBytes = 1 << 20
+	maxRedirects            = 3
+)
+
+var defaultAllowedSchemes = []string{"https", "http"}
+
+// blockedPrefixes are ranges not covered by the net.IP helpers that still reach
+// infrastructure rather than the public internet.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can embed any IPv4 address
+}
+
+// urlPolicy decides which URLs an HTTP tool may fetch.
+type urlPolicy struct {
+	schemes      []string
+	hosts        []string
+	allowPrivate bool
+}
+
+func newURLPolicy(cfg ToolConfig) *urlPolicy {
+	p := &urlPolicy{
+		schemes:      cfg.AllowedSchemes,
+		hosts:        cfg.AllowedHosts,
+		allowPrivate: cfg.AllowPrivateNetworks,
+	}
+	if len(p.schemes) == 0 {
+		p.schemes = defaultAllowedSchemes
+	}
+	// 未显式配置时，只允许访问预设 URL 所在的主机
+	if len(p.hosts) == 0 && cfg.URL != "" {
+		// 主机部分本身是模板时无法推断，此时只做地址检查
+		prefix, _, templated := strings.Cut(cfg.URL, "{{")
+		u, err := url.Parse(prefix)
+		if err == nil && u.Host != "" && (!templated || len(prefix) > len(u.Scheme)+len("://")+len(u.Host)) {
+			p.hosts = []string{u.Hostname()}
+		}
+	}
+	return p
+}
+
+// check validates the scheme and host of u before any connection is made.
+func (p *urlPolicy) check(u *url.URL) error {
+	if !containsFold(p.schemes, u.Scheme) {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("url has no host")
+	}
+	if len(p.hosts) > 0 && !hostAllowed(p.hosts, host) {
+		return fmt.Errorf("host %s is not allowed", host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !p.allowPrivate && isBlockedAddr(addr) {
+		return fmt.Errorf("address %s is not allowed: private network", addr)
+	}
+	return nil
+}
+
+// hostAllowed matches host against exact names and *.example.com wildcards.
+func hostAllowed(allowed []string, host string) bool {
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if suffix, ok := strings.CutPrefix(a, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == a {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// client returns an HTTP client that enforces the policy on every redirect and
+// checks the address actually dialed, so DNS names resolving to internal hosts
+// (including rebinding between check and connect) are refused too.
+func (p *urlPolicy) client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if p.allowPrivate {
+				return nil
+			}
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("invalid address %s: %v", address, err)
+			}
+			if isBlockedAddr(ap.Addr()) {
+				return fmt.Errorf("address %s is not allowed: private network", ap.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// 不走环境变量中的代理，否则拨号检查的是代理地址
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if err := p.check(req.URL); err != nil {
+				return fmt.Errorf("redirect to %s refused: %v", req.URL.Redacted(), err)
+			}
+			return nil
+		},
+	}
+}
+
+// readBody reads at most maxBytes of a successful response.
+func readBody(resp *http.Response, maxBytes int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxBytes)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, truncate(string(data), 200))
+	}
+	return data, nil
+}
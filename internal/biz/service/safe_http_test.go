@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestURLPolicy(t *testing.T) {
+	p := newURLPolicy(ToolConfig{URL: "https://api.example.com/v1?q={{.q}}"})
+	cases := map[string]bool{
+		"https://api.example.com/v1?q=1":       true,
+		"http://api.example.com/v1":            true,
+		"https://evil.com/":                    false,
+		"file:///etc/passwd":                   false,
+		"https://169.254.169.254/latest/meta":  false,
+		"https://api.example.com.evil.com/v1":  false,
+		"gopher://api.example.com/":            false,
+		"https://API.EXAMPLE.COM/v1?q=upper":   true,
+		"https://api.example.com:8443/v1?q=ok": true,
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if err := p.check(u); (err == nil) != want {
+			t.Errorf("expect allowed=%v for %s, but got %v", want, raw, err)
+		}
+	}
+
+	// a templated host cannot be pinned, so only addresses are checked
+	p = newURLPolicy(ToolConfig{URL: "https://{{.host}}/", AllowedHosts: []string{"*.example.com"}})
+	for raw, want := range map[string]bool{
+		"https://a.example.com/": true,
+		"https://example.com/":   false,
+		"https://10.0.0.1/":      false,
+	} {
+		u, _ := url.Parse(raw)
+		if err := p.check(u); (err == nil) != want {
+			t.Errorf("expect allowed=%v for %s, but got %v", want, raw, err)
+		}
+	}
+}
+
+func TestIsBlockedAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fd00::1":          true,
+		"fe80::1":          true,
+		"::ffff:127.0.0.1": true,
+		"8.8.8.8":          false,
+		"2606:4700::1111":  false,
+	} {
+		if got := isBlockedAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("expect blocked=%v for %s, but got %v", want, addr, got)
+		}
+	}
+}
+
+func TestHTTPTool_Safety(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		case "/big":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`"` + strings.Repeat("x", 100) + `"`))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	defer srv.Close()
+
+	// loopback is refused by default, even for the configured host
+	blocked, _ := NewHTTPTool(ToolConfig{Name: "blocked", Type: ToolTypeHTTP, URL: srv.URL})
+	if _, err := blocked.InvokableRun(context.Background(), `{}`); err == nil || !strings.Contains(err.Error(), "private network") {
+		t.Errorf("expect private network error, but got %v", err)
+	}
+
+	local, _ := NewHTTPTool(ToolConfig{Name: "local", Type: ToolTypeHTTP, URL: srv.URL, AllowPrivateNetworks: true, MaxResponseBytes: 64})
+	cases := map[string]string{
+		srv.URL + "/redirect": "redirect to",
+		srv.URL + "/big":      "exceeds 64 bytes",
+		srv.URL + "/image":    "unsupported content type",
+		srv.URL + "/missing":  "unexpected status 404",
+		"https://example.com": "host example.com is not allowed",
+	}
+	for u, want := range cases {
+		_, err := local.InvokableRun(context.Background(), `{"url":"`+u+`"}`)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expect error containing %q for %s, but got %v", want, u, err)
+		}
+	}
+	if out, err := local.InvokableRun(context.Background(), `{}`); err != nil || out != `{"ok":true}` {
+		t.Errorf("expect %s, but got %s, %v", `{"ok":true}`, out, err)
+	}
+}
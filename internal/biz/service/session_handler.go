@@ -0,0 +1,237 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSessionTitleLen bounds the title derived from a session's first user message.
+const maxSessionTitleLen = 40
+
+// sessionSummary describes a session in the list returned by HandleListSessions.
+type sessionSummary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Messages  int       `json:"messages"`
+	Usage     Usage     `json:"usage"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func summarizeSession(s *Session) sessionSummary {
+	sum := sessionSummary{
+		ID:        s.ID,
+		Messages:  len(s.History),
+		Usage:     s.Usage,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+	for _, msg := range s.History {
+		if msg.Role == schema.User {
+			sum.Title = truncate(strings.TrimSpace(userText(msg)), maxSessionTitleLen)
+			break
+		}
+	}
+	return sum
+}
+
+// loadSession loads the session named in the path, answering 404 or 500 itself
+// when it cannot.
+func loadSession(c *gin.Context) (*Session, bool) {
+	sess, err := DefaultSessionStore().Load(c.Param("id"))
+	if errors.Is(err, ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return sess, true
+}
+
+// lockSessionTurn takes the turn lock of the session named in the path, so changes
+// to it cannot be overwritten by a turn that is still running; it answers 409 itself
+// when the session stays busy.
+func lockSessionTurn(c *gin.Context) (func(), bool) {
+	release, err := lockSession(c.Request.Context(), c.Param("id"), 0)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return release, true
+}
+
+// HandleListSessions lists the stored sessions, most recently used first.
+func HandleListSessions(c *gin.Context) {
+	sessions, err := DefaultSessionStore().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	list := make([]sessionSummary, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, summarizeSession(s))
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": list})
+}
+
+// HandleGetSession returns a session with its full history, tool calls and tool
+// results included.
+func HandleGetSession(c *gin.Context) {
+	sess, ok := loadSession(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, sess)
+}
+
+// HandleDeleteSession removes a session.
+func HandleDeleteSession(c *gin.Context) {
+	release, ok := lockSessionTurn(c)
+	if !ok {
+		return
+	}
+	defer release()
+	if err := DefaultSessionStore().Delete(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// HandleResetSession clears a session's history but keeps the session.
+func HandleResetSession(c *gin.Context) {
+	release, ok := lockSessionTurn(c)
+	if !ok {
+		return
+	}
+	defer release()
+	sess, ok := loadSession(c)
+	if !ok {
+		return
+	}
+	sess.History, sess.Summary = nil, nil
+	if err := DefaultSessionStore().Save(sess); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summarizeSession(sess))
+}
+
+// forkRequest is the body of HandleForkSession. The fork gets the first Index
+// messages of the session; SessionID names it, a random id is used when empty.
+type forkRequest struct {
+	Index     *int   `json:"index"`
+	SessionID string `json:"sessionId"`
+}
+
+// HandleForkSession copies the beginning of a session into a new one, so the
+// conversation can continue from there in a different direction.
+func HandleForkSession(c *gin.Context) {
+	var req forkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	release, ok := lockSessionTurn(c)
+	if !ok {
+		return
+	}
+	defer release()
+	sess, ok := loadSession(c)
+	if !ok {
+		return
+	}
+	index := len(sess.History)
+	if req.Index != nil {
+		index = *req.Index
+	}
+	if err := checkForkPoint(sess.History, index); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.SessionID == "" {
+		req.SessionID = sess.ID + "-" + randomID()
+	}
+	if _, err := DefaultSessionStore().Load(req.SessionID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("session %s already exists", req.SessionID)})
+		return
+	}
+	fork := &Session{ID: req.SessionID, History: sess.History[:index]}
+	if err := DefaultSessionStore().Save(fork); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if saved, err := DefaultSessionStore().Load(fork.ID); err == nil {
+		fork = saved
+	}
+	c.JSON(http.StatusCreated, summarizeSession(fork))
+}
+
+// checkForkPoint rejects an index that would separate a tool call from its results.
+func checkForkPoint(history []*schema.Message, index int) error {
+	if index < 0 || index > len(history) {
+		return fmt.Errorf("index %d out of range [0, %d]", index, len(history))
+	}
+	if index < len(history) && history[index].Role == schema.Tool {
+		return fmt.Errorf("index %d splits a tool call from its results", index)
+	}
+	if index > 0 && len(history[index-1].ToolCalls) > 0 {
+		return fmt.Errorf("index %d splits a tool call from its results", index)
+	}
+	return nil
+}
+
+// HandleExportSession returns a session's transcript as a JSON or, with
+// ?format=markdown, a Markdown download.
+func HandleExportSession(c *gin.Context) {
+	sess, ok := loadSession(c)
+	if !ok {
+		return
+	}
+	switch format := c.DefaultQuery("format", "json"); format {
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, sess.ID))
+		c.JSON(http.StatusOK, sess)
+	case "markdown", "md":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.md"`, sess.ID))
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(sessionMarkdown(sess)))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported format %q", format)})
+	}
+}
+
+func sessionMarkdown(s *Session) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 会话 %s\n\n", s.ID)
+	fmt.Fprintf(&b, "- 创建时间：%s\n- 更新时间：%s\n", s.CreatedAt.Format(time.RFC3339), s.UpdatedAt.Format(time.RFC3339))
+	for _, msg := range s.History {
+		switch msg.Role {
+		case schema.User:
+			b.WriteString("\n## 用户\n\n")
+		case schema.Assistant:
+			b.WriteString("\n## 助手\n\n")
+		case schema.Tool:
+			fmt.Fprintf(&b, "\n## 工具结果 `%s`\n\n```\n%s\n```\n", msg.ToolName, msg.Content)
+			continue
+		default:
+			fmt.Fprintf(&b, "\n## %s\n\n", msg.Role)
+		}
+		if text := userText(msg); text != "" {
+			b.WriteString(text + "\n")
+		}
+		for _, tc := range msg.ToolCalls {
+			fmt.Fprintf(&b, "\n> 调用工具 `%s`：`%s`\n", tc.Function.Name, tc.Function.Arguments)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestSessionHandlers(t *testing.T) {
+	store := DefaultSessionStore()
+	SetSessionStore(NewMemorySessionStore(0, 0))
+	defer SetSessionStore(store)
+	DefaultSessionStore().Save(&Session{ID: "api", History: []*schema.Message{
+		schema.UserMessage("数据库里有什么？"),
+		schema.AssistantMessage("", []schema.ToolCall{{ID: "call-1", Function: schema.FunctionCall{Name: "local_db", Arguments: `{"query":"select 1"}`}}}),
+		schema.ToolMessage(`[{"1":1}]`, "call-1", schema.WithToolName("local_db")),
+		schema.AssistantMessage("只有一行数据。", nil),
+	}})
+	srv := newTestServer(t)
+
+	do := func(method, path, body string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	var list struct{ Sessions []sessionSummary }
+	_, body := do(http.MethodGet, "/ai/sessions", "")
+	json.Unmarshal([]byte(body), &list)
+	if len(list.Sessions) != 1 || list.Sessions[0].Title != "数据库里有什么？" || list.Sessions[0].Messages != 4 {
+		t.Errorf("expect one listed session, but got %s", body)
+	}
+
+	var sess Session
+	_, body = do(http.MethodGet, "/ai/sessions/api", "")
+	json.Unmarshal([]byte(body), &sess)
+	if len(sess.History) != 4 || sess.History[1].ToolCalls[0].Function.Name != "local_db" || sess.History[2].ToolCallID != "call-1" {
+		t.Errorf("expect full history with tool calls, but got %s", body)
+	}
+
+	forks := []struct {
+		body   string
+		expect int
+	}{
+		{`{"index": 2}`, http.StatusBadRequest},
+		{`{"index": 0}`, http.StatusCreated},
+		{`{"index": 9}`, http.StatusBadRequest},
+		{`{"index": 1, "sessionId": "api-fork"}`, http.StatusCreated},
+		{`{"sessionId": "api-fork"}`, http.StatusConflict},
+	}
+	for _, f := range forks {
+		if code, body := do(http.MethodPost, "/ai/sessions/api/fork", f.body); code != f.expect {
+			t.Errorf("fork %s: expect %d, but got %d %s", f.body, f.expect, code, body)
+		}
+	}
+	if fork := mustLoad(t, "api-fork"); len(fork.History) != 1 {
+		t.Errorf("expect %d forked message, but got %d", 1, len(fork.History))
+	}
+
+	_, body = do(http.MethodGet, "/ai/sessions/api/export?format=markdown", "")
+	for _, want := range []string{"## 用户", "调用工具 `local_db`", "## 工具结果 `local_db`", "只有一行数据。"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expect markdown containing %q, but got %s", want, body)
+		}
+	}
+	if code, _ := do(http.MethodGet, "/ai/sessions/api/export?format=pdf", ""); code != http.StatusBadRequest {
+		t.Errorf("expect %d, but got %d", http.StatusBadRequest, code)
+	}
+
+	if _, body := do(http.MethodPost, "/ai/sessions/api/reset", ""); !strings.Contains(body, `"messages":0`) {
+		t.Errorf("expect reset session, but got %s", body)
+	}
+	if code, _ := do(http.MethodDelete, "/ai/sessions/api", ""); code != http.StatusNoContent {
+		t.Errorf("expect %d, but got %d", http.StatusNoContent, code)
+	}
+	if code, _ := do(http.MethodGet, "/ai/sessions/api", ""); code != http.StatusNotFound {
+		t.Errorf("expect %d, but got %d", http.StatusNotFound, code)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// DefaultSessionWait is how long a turn waits for the previous turn of the same
+// session to finish before giving up with ErrSessionBusy.
+const DefaultSessionWait = 30 * time.Second
+
+// ErrSessionBusy is returned when another turn of the same session is still running.
+var ErrSessionBusy = errors.New("session is busy with another turn")
+
+// WithSessionWait sets how long a turn waits for other turns of its session; d <= 0
+// means DefaultSessionWait.
+func WithSessionWait(d time.Duration) Option {
+	return func(o *AgentOptions) {
+		o.SessionWait = d
+	}
+}
+
+type sessionLock struct {
+	turn chan struct{}
+	refs int
+}
+
+var (
+	sessionLocksMtx sync.Mutex
+	sessionLocks    = make(map[string]*sessionLock)
+)
+
+// lockSession serializes the turns of a session across agents, requests and
+// connections. It waits up to wait for the running turn and returns the function
+// that ends this one.
+func lockSession(ctx context.Context, id string, wait time.Duration) (func(), error) {
+	if wait <= 0 {
+		wait = DefaultSessionWait
+	}
+	sessionLocksMtx.Lock()
+	l, ok := sessionLocks[id]
+	if !ok {
+		l = &sessionLock{turn: make(chan struct{}, 1)}
+		sessionLocks[id] = l
+	}
+	l.refs++
+	sessionLocksMtx.Unlock()
+
+	unref := func() {
+		sessionLocksMtx.Lock()
+		defer sessionLocksMtx.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(sessionLocks, id)
+		}
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-l.turn
+			unref()
+		})
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case l.turn <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		unref()
+		return nil, ErrSessionBusy
+	case <-ctx.Done():
+		unref()
+		return nil, ctx.Err()
+	}
+}
+
+// loadStoredSession returns the stored session, empty when it does not exist yet.
+func loadStoredSession(store SessionStore, id string) (*Session, error) {
+	sess, err := store.Load(id)
+	if errors.Is(err, ErrSessionNotFound) {
+		return &Session{ID: id, History: make([]*schema.Message, 0)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %v", err)
+	}
+	return sess, nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gorilla/websocket"
+)
+
+func TestLockSession(t *testing.T) {
+	ctx := context.Background()
+	release, err := lockSession(ctx, "lock", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockSession(ctx, "lock", time.Millisecond); !errors.Is(err, ErrSessionBusy) {
+		t.Errorf("expect %v, but got %v", ErrSessionBusy, err)
+	}
+	if other, err := lockSession(ctx, "other", time.Millisecond); err != nil {
+		t.Errorf("expect other sessions to be free, but got %v", err)
+	} else {
+		other()
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+		release()
+	}()
+	second, err := lockSession(ctx, "lock", time.Second)
+	if err != nil {
+		t.Fatalf("expect to get the lock once released, but got %v", err)
+	}
+	second()
+
+	sessionLocksMtx.Lock()
+	defer sessionLocksMtx.Unlock()
+	if _, ok := sessionLocks["lock"]; ok {
+		t.Errorf("expect released locks to be dropped")
+	}
+}
+
+func TestMock_SessionBusy(t *testing.T) {
+	ctx := context.Background()
+	slow, _ := NewAgent(MockAgent, "busy", ctx, WithMockScript(&MockScript{ChunkDelay: 20 * time.Millisecond}))
+	reader, err := slow.ChatStream(ctx, "慢慢说")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := NewAgent(MockAgent, "busy", ctx, WithSessionWait(time.Millisecond))
+	if _, err := other.Chat(ctx, "插话"); !errors.Is(err, ErrSessionBusy) {
+		t.Errorf("expect %v, but got %v", ErrSessionBusy, err)
+	}
+
+	// closing the stream early ends the turn as well
+	reader.Close()
+	next, _ := NewAgent(MockAgent, "busy", ctx, WithSessionWait(time.Second))
+	if _, err := next.Chat(ctx, "轮到我了"); err != nil {
+		t.Errorf("expect the turn to run after the stream is closed, but got %v", err)
+	}
+}
+
+// hammerSession runs turns on one session from every handler at once with agents
+// of agentType; run with -race. It returns the session's history afterwards.
+func hammerSession(t *testing.T, agentType AgentType, perHandler int) []*schema.Message {
+	t.Helper()
+	store := DefaultSessionStore()
+	SetSessionStore(NewMemorySessionStore(0, 0))
+	defer SetSessionStore(store)
+	srv := newTestServer(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*perHandler)
+	run := func(f func(i int) error) {
+		for i := range perHandler {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := f(i); err != nil {
+					errs <- err
+				}
+			}()
+		}
+	}
+
+	run(func(i int) error {
+		resp, err := http.Get(srv.URL + "/ai/doubao?agentType=" + string(agentType) + "&sessionId=hammer&content=" + url.QueryEscape(fmt.Sprintf("http %d", i)))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("http %d: %s", resp.StatusCode, body)
+		}
+		return nil
+	})
+	run(func(i int) error {
+		resp, err := http.Post(srv.URL+"/ai/sse", "application/json",
+			strings.NewReader(fmt.Sprintf(`{"sessionId": "hammer", "agentType": %q, "content": "sse %d"}`, agentType, i)))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if !strings.Contains(string(body), `"event":"end"`) || strings.Contains(string(body), `"event":"error"`) {
+			return fmt.Errorf("sse: %s", body)
+		}
+		return nil
+	})
+	run(func(i int) error {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		conn.WriteJSON(wsRequest{Type: "chat", SessionID: "hammer", AgentType: string(agentType), Content: fmt.Sprintf("ws %d", i)})
+		for {
+			var ev wsEvent
+			if err := conn.ReadJSON(&ev); err != nil {
+				return err
+			}
+			if ev.Type == "error" {
+				return fmt.Errorf("ws: %s", ev.Content)
+			}
+			if ev.Event == "end" {
+				return nil
+			}
+		}
+	})
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	return mustLoad(t, "hammer").History
+}
+
+// TestConcurrentTurns checks that no turn is lost to another overwriting the
+// session.
+func TestConcurrentTurns(t *testing.T) {
+	const perHandler = 5
+	history := hammerSession(t, MockAgent, perHandler)
+	if len(history) != 2*3*perHandler {
+		t.Fatalf("expect %d messages, but got %d", 2*3*perHandler, len(history))
+	}
+	for i := 0; i < len(history); i += 2 {
+		if history[i].Role != schema.User || history[i+1].Content != "[mock] "+history[i].Content {
+			t.Errorf("expect turns to stay in order, but got %q then %q", history[i].Content, history[i+1].Content)
+		}
+	}
+}
+
+// TestConcurrentTurns_OpenAI runs the same turns through a model backend, each with
+// a tool round against the OpenAI stub.
+func TestConcurrentTurns_OpenAI(t *testing.T) {
+	const perHandler = 3
+	stub := newOpenAIStub(t)
+	defer stub.Close()
+	t.Setenv("OPENAI_BASE_URL", stub.URL+"/v1")
+	tools := NewToolRegistry()
+	tools.Register(context.Background(), utils.NewTool[echoInput, string](
+		&schema.ToolInfo{Name: "echo", Desc: "echo"},
+		func(ctx context.Context, in echoInput) (string, error) {
+			return in.Text, nil
+		},
+	))
+	SetToolRegistry(tools)
+	defer SetToolRegistry(NewToolRegistry())
+
+	history := hammerSession(t, OpenAIAgent, perHandler)
+	// user, assistant(tool call), tool, answer
+	if len(history) != 4*3*perHandler {
+		t.Fatalf("expect %d messages, but got %d", 4*3*perHandler, len(history))
+	}
+	for i := 0; i < len(history); i += 4 {
+		if history[i].Role != schema.User || len(history[i+1].ToolCalls) != 1 || history[i+2].Role != schema.Tool || history[i+3].Content != "done hi" {
+			t.Errorf("expect whole turns in order, but got %v", history[i:i+4])
+		}
+	}
+}
+
+func TestDeleteSessionDuringTurn(t *testing.T) {
+	store := DefaultSessionStore()
+	SetSessionStore(NewMemorySessionStore(0, 0))
+	defer SetSessionStore(store)
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	agent, _ := NewAgent(MockAgent, "deleted", ctx, WithMockScript(&MockScript{ChunkDelay: 20 * time.Millisecond}))
+	reader, err := agent.ChatStream(ctx, "说一段很长的话")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := make(chan int)
+	go func() {
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/ai/sessions/deleted", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			deleted <- 0
+			return
+		}
+		resp.Body.Close()
+		deleted <- resp.StatusCode
+	}()
+	for {
+		if _, err := reader.Recv(); err != nil {
+			break
+		}
+	}
+	if code := <-deleted; code != http.StatusNoContent {
+		t.Fatalf("expect %d, but got %d", http.StatusNoContent, code)
+	}
+	// the delete waited for the turn, so the turn cannot bring the session back
+	if _, err := DefaultSessionStore().Load("deleted"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expect the session to stay deleted, but got %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	DefaultSessionCapacity = 1024
+	DefaultSessionTTL      = 24 * time.Hour
+)
+
+// ErrSessionNotFound is returned by a SessionStore when no live session exists for an id.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is the persisted state of a single conversation.
+type Session struct {
+	ID        string            `json:"id"`
+	History   []*schema.Message `json:"history"`
+	Usage     Usage             `json:"usage"`
+	Summary   *HistorySummary   `json:"summary,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// SessionStore persists conversations between requests. Sessions that have not been
+// saved within the store's TTL are treated as missing and evicted.
+type SessionStore interface {
+	Load(id string) (*Session, error)
+	Save(s *Session) error
+	Delete(id string) error
+	List() ([]*Session, error)
+}
+
+var defaultSessionStore SessionStore = NewMemorySessionStore(DefaultSessionCapacity, DefaultSessionTTL)
+
+// SetSessionStore replaces the store used by agents that are not given one explicitly.
+func SetSessionStore(store SessionStore) {
+	defaultSessionStore = store
+}
+
+// DefaultSessionStore returns the store used by agents that are not given one explicitly.
+func DefaultSessionStore() SessionStore {
+	return defaultSessionStore
+}
+
+func (s *Session) clone() *Session {
+	cp := *s
+	cp.History = append([]*schema.Message(nil), s.History...)
+	return &cp
+}
+
+func (s *Session) expired(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(s.UpdatedAt) > ttl
+}
+
+// MemorySessionStore keeps sessions in process memory, evicting the least recently
+// used one once capacity is reached.
+type MemorySessionStore struct {
+	capacity int
+	ttl      time.Duration
+	mtx      sync.Mutex
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+var _ SessionStore = (*MemorySessionStore)(nil)
+
+// NewMemorySessionStore creates an LRU store. A capacity or ttl <= 0 disables that limit.
+func NewMemorySessionStore(capacity int, ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (m *MemorySessionStore) Load(id string) (*Session, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	e, ok := m.items[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	s := e.Value.(*Session)
+	if s.expired(m.ttl, time.Now()) {
+		m.remove(e)
+		return nil, ErrSessionNotFound
+	}
+	m.ll.MoveToFront(e)
+	return s.clone(), nil
+}
+
+func (m *MemorySessionStore) Save(s *Session) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	cp := s.clone()
+	cp.UpdatedAt = time.Now()
+	if e, ok := m.items[s.ID]; ok {
+		if cp.CreatedAt.IsZero() {
+			cp.CreatedAt = e.Value.(*Session).CreatedAt
+		}
+		e.Value = cp
+		m.ll.MoveToFront(e)
+		return nil
+	}
+	if cp.CreatedAt.IsZero() {
+		cp.CreatedAt = cp.UpdatedAt
+	}
+	m.items[s.ID] = m.ll.PushFront(cp)
+	for m.capacity > 0 && m.ll.Len() > m.capacity {
+		m.remove(m.ll.Back())
+	}
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if e, ok := m.items[id]; ok {
+		m.remove(e)
+	}
+	return nil
+}
+
+// List returns the live sessions, most recently used first.
+func (m *MemorySessionStore) List() ([]*Session, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	now := time.Now()
+	sessions := make([]*Session, 0, m.ll.Len())
+	for e := m.ll.Front(); e != nil; {
+		next := e.Next()
+		s := e.Value.(*Session)
+		if s.expired(m.ttl, now) {
+			m.remove(e)
+		} else {
+			sessions = append(sessions, s.clone())
+		}
+		e = next
+	}
+	return sessions, nil
+}
+
+func (m *MemorySessionStore) remove(e *list.Element) {
+	m.ll.Remove(e)
+	delete(m.items, e.Value.(*Session).ID)
+}
+
+// FileSessionStore keeps all sessions in a single JSON file so conversations survive
+// restarts. The file is rewritten atomically on every change.
+type FileSessionStore struct {
+	path     string
+	ttl      time.Duration
+	mtx      sync.Mutex
+	sessions map[string]*Session
+}
+
+var _ SessionStore = (*FileSessionStore)(nil)
+
+// NewFileSessionStore opens (or creates) the store backed by path. A ttl <= 0 keeps
+// sessions forever.
+func NewFileSessionStore(path string, ttl time.Duration) (*FileSessionStore, error) {
+	f := &FileSessionStore{
+		path:     path,
+		ttl:      ttl,
+		sessions: make(map[string]*Session),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read session file: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &f.sessions); err != nil {
+			return nil, fmt.Errorf("failed to decode session file: %v", err)
+		}
+	}
+	return f, nil
+}
+
+func (f *FileSessionStore) Load(id string) (*Session, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	s, ok := f.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if s.expired(f.ttl, time.Now()) {
+		delete(f.sessions, id)
+		return nil, ErrSessionNotFound
+	}
+	return s.clone(), nil
+}
+
+func (f *FileSessionStore) Save(s *Session) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	cp := s.clone()
+	cp.UpdatedAt = time.Now()
+	if cp.CreatedAt.IsZero() {
+		if old, ok := f.sessions[s.ID]; ok {
+			cp.CreatedAt = old.CreatedAt
+		} else {
+			cp.CreatedAt = cp.UpdatedAt
+		}
+	}
+	f.sessions[s.ID] = cp
+	return f.flush()
+}
+
+func (f *FileSessionStore) Delete(id string) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if _, ok := f.sessions[id]; !ok {
+		return nil
+	}
+	delete(f.sessions, id)
+	return f.flush()
+}
+
+// List returns the live sessions, most recently updated first.
+func (f *FileSessionStore) List() ([]*Session, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	now := time.Now()
+	sessions := make([]*Session, 0, len(f.sessions))
+	for id, s := range f.sessions {
+		if s.expired(f.ttl, now) {
+			delete(f.sessions, id)
+			continue
+		}
+		sessions = append(sessions, s.clone())
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// flush drops expired sessions and writes the rest to disk. Callers hold f.mtx.
+func (f *FileSessionStore) flush() error {
+	now := time.Now()
+	for id, s := range f.sessions {
+		if s.expired(f.ttl, now) {
+			delete(f.sessions, id)
+		}
+	}
+	data, err := json.Marshal(f.sessions)
+	if err != nil {
+		return fmt.Errorf("failed to encode sessions: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write session file: %v", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write session file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write session file: %v", err)
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestMemorySessionStore_LRU(t *testing.T) {
+	store := NewMemorySessionStore(2, 0)
+	for _, id := range []string{"a", "b"} {
+		store.Save(&Session{ID: id})
+	}
+	// touch "a" so "b" becomes the least recently used
+	if _, err := store.Load("a"); err != nil {
+		t.Fatalf("expect session a, but got %v", err)
+	}
+	store.Save(&Session{ID: "c"})
+
+	if _, err := store.Load("b"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expect b to be evicted, but got %v", err)
+	}
+	sessions, _ := store.List()
+	if len(sessions) != 2 || sessions[0].ID != "c" || sessions[1].ID != "a" {
+		t.Errorf("expect [c a], but got %v", sessions)
+	}
+}
+
+func TestMemorySessionStore_TTL(t *testing.T) {
+	store := NewMemorySessionStore(0, 10*time.Millisecond)
+	store.Save(&Session{ID: "a"})
+	time.Sleep(20 * time.Millisecond)
+	if _, err := store.Load("a"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expect a to be expired, but got %v", err)
+	}
+}
+
+func TestMemorySessionStore_Isolation(t *testing.T) {
+	store := NewMemorySessionStore(0, 0)
+	sess := &Session{ID: "a", History: []*schema.Message{schema.UserMessage("hi")}}
+	store.Save(sess)
+	sess.History = append(sess.History, schema.UserMessage("not saved"))
+
+	loaded, _ := store.Load("a")
+	if len(loaded.History) != 1 {
+		t.Errorf("expect %d messages, but got %d", 1, len(loaded.History))
+	}
+}
+
+func TestFileSessionStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := NewFileSessionStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Save(&Session{ID: "a", History: []*schema.Message{schema.UserMessage("hi")}})
+	store.Save(&Session{ID: "b"})
+	store.Delete("b")
+
+	reopened, err := NewFileSessionStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := reopened.Load("a")
+	if err != nil {
+		t.Fatalf("expect session a, but got %v", err)
+	}
+	if len(sess.History) != 1 || sess.History[0].Content != "hi" {
+		t.Errorf("expect history [hi], but got %v", sess.History)
+	}
+	if _, err := reopened.Load("b"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expect b to be deleted, but got %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxSSEBufferedEvents bounds the events kept per stream for resumption; a client
+	// resuming from an older event gets an error instead.
+	maxSSEBufferedEvents = 1024
+	// sseResumeTTL is how long a finished stream can still be resumed.
+	sseResumeTTL = 5 * time.Minute
+	// sseDetachTimeout is how long a turn keeps generating with no client attached
+	// before it is cancelled.
+	sseDetachTimeout = 30 * time.Second
+)
+
+// sseHeartbeatInterval is how often an idle stream sends a comment line to keep
+// proxies from closing it.
+var sseHeartbeatInterval = 15 * time.Second
+
+type sseEvent struct {
+	seq  int
+	data []byte
+}
+
+// sseStream buffers the events of one turn so that clients can follow it and, after
+// a dropped connection, resume it with Last-Event-ID. The turn runs on its own
+// context and does not end with the request that started it.
+type sseStream struct {
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mtx        sync.Mutex
+	events     []sseEvent
+	next       int
+	done       bool
+	finishedAt time.Time
+	changed    chan struct{}
+	clients    int
+	detached   *time.Timer
+}
+
+// publish appends the JSON encoding of v to the stream.
+func (s *sseStream) publish(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[SSE] failed to encode event: %v", err)
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.done {
+		return
+	}
+	s.next++
+	s.events = append(s.events, sseEvent{seq: s.next, data: data})
+	if len(s.events) > maxSSEBufferedEvents {
+		s.events = append(s.events[:0:0], s.events[len(s.events)-maxSSEBufferedEvents:]...)
+	}
+	s.notify()
+}
+
+// finish marks the stream complete.
+func (s *sseStream) finish() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.done, s.finishedAt = true, time.Now()
+	s.notify()
+	s.cancel()
+}
+
+func (s *sseStream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// since returns the events after seq, whether the stream is complete, and a channel
+// closed on the next change. ok is false when events after seq were already dropped
+// from the buffer.
+func (s *sseStream) since(seq int) (events []sseEvent, done bool, changed <-chan struct{}, ok bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if len(s.events) > 0 && seq < s.events[0].seq-1 {
+		return nil, s.done, s.changed, false
+	}
+	i := len(s.events)
+	for i > 0 && s.events[i-1].seq > seq {
+		i--
+	}
+	return s.events[i:], s.done, s.changed, true
+}
+
+func (s *sseStream) attach() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.clients++
+	if s.detached != nil {
+		s.detached.Stop()
+		s.detached = nil
+	}
+}
+
+// detach cancels the turn if no client comes back within sseDetachTimeout.
+func (s *sseStream) detach() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.clients--
+	if s.clients > 0 || s.done {
+		return
+	}
+	s.detached = time.AfterFunc(sseDetachTimeout, func() {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if s.clients == 0 {
+			s.cancel()
+		}
+	})
+}
+
+// randomID returns a random 16-digit hex id.
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sseHub keeps the running and recently finished streams.
+type sseHub struct {
+	mtx     sync.Mutex
+	streams map[string]*sseStream
+}
+
+var defaultSSEHub = &sseHub{streams: make(map[string]*sseStream)}
+
+// open creates a stream, dropping the finished ones past sseResumeTTL.
+func (h *sseHub) open() *sseStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &sseStream{
+		id:      randomID(),
+		ctx:     ctx,
+		cancel:  cancel,
+		changed: make(chan struct{}),
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for id, old := range h.streams {
+		old.mtx.Lock()
+		expired := old.done && time.Since(old.finishedAt) > sseResumeTTL
+		old.mtx.Unlock()
+		if expired {
+			delete(h.streams, id)
+		}
+	}
+	h.streams[s.id] = s
+	return s
+}
+
+// resume looks up the stream and position named by a Last-Event-ID.
+func (h *sseHub) resume(lastEventID string) (*sseStream, int, bool) {
+	id, seq, ok := strings.Cut(lastEventID, "-")
+	if !ok {
+		return nil, 0, false
+	}
+	n, err := strconv.Atoi(seq)
+	if err != nil {
+		return nil, 0, false
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	s, ok := h.streams[id]
+	return s, n, ok
+}
+
+// serveSSE writes the events of s after seq to the client until the stream is
+// complete or the client goes away, with a comment heartbeat while it is idle.
+func serveSSE(c *gin.Context, s *sseStream, seq int) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	s.attach()
+	defer s.detach()
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	w := c.Writer
+	for {
+		events, done, changed, ok := s.since(seq)
+		if !ok {
+			data, _ := json.Marshal(AgentEvent{Event: "error", Content: "resume point expired"})
+			fmt.Fprintf(w, "event:stringevent\ndata:%s\n\n", data)
+			w.Flush()
+			return
+		}
+		for _, ev := range events {
+			fmt.Fprintf(w, "id:%s-%d\nevent:stringevent\ndata:%s\n\n", s.id, ev.seq, ev.data)
+			seq = ev.seq
+		}
+		w.Flush()
+		if done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			io.WriteString(w, ": ping\n\n")
+			w.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
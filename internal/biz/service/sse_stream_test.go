@@ -0,0 +1,91 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sseIDPattern = regexp.MustCompile(`(?m)^id:(\S+)$`)
+
+func TestHandleSSE_PostAndResume(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Post(srv.URL+"/ai/sse", "application/json",
+		strings.NewReader(`{"sessionId": "sse-post", "agentType": "mock", "content": "ping"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	body := string(data)
+
+	// three message chunks and the end event, each with its own id
+	ids := sseIDPattern.FindAllStringSubmatch(body, -1)
+	if len(ids) != 4 || !strings.Contains(body, `"event":"end"`) {
+		t.Fatalf("expect 4 events with ids, but got %s", body)
+	}
+	if !strings.Contains(body, `"usage":{"turn":{"promptTokens"`) {
+		t.Errorf("expect usage in the end event, but got %s", body)
+	}
+
+	// resuming after the first chunk replays the rest
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/ai/sse", nil)
+	req.Header.Set("Last-Event-ID", ids[0][1])
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resumed := sseIDPattern.FindAllStringSubmatch(string(data), -1)
+	if len(resumed) != 3 || resumed[0][1] != ids[1][1] {
+		t.Errorf("expect events after %s, but got %s", ids[0][1], data)
+	}
+
+	req.Header.Set("Last-Event-ID", "unknown-1")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expect %d for unknown stream, but got %d", http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func TestHandleSSE_Heartbeat(t *testing.T) {
+	interval := sseHeartbeatInterval
+	sseHeartbeatInterval = 5 * time.Millisecond
+	defer func() { sseHeartbeatInterval = interval }()
+	registerScriptedAgent(t, "scripted-slow", &MockScript{ChunkDelay: 50 * time.Millisecond})
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/ai/sse?agentType=scripted-slow&sessionId=sse-heartbeat&content=hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(data), ": ping\n\n") || !strings.Contains(string(data), `"event":"end"`) {
+		t.Errorf("expect heartbeat comments in the stream, but got %s", data)
+	}
+}
+
+func TestSSEStream_Buffer(t *testing.T) {
+	s := defaultSSEHub.open()
+	for i := range maxSSEBufferedEvents + 2 {
+		s.publish(i)
+	}
+	s.finish()
+	if _, _, _, ok := s.since(1); ok {
+		t.Errorf("expect dropped events not to be resumable")
+	}
+	events, done, _, ok := s.since(maxSSEBufferedEvents)
+	if !ok || !done || len(events) != 2 {
+		t.Errorf("expect %d buffered events after %d, but got %d", 2, maxSSEBufferedEvents, len(events))
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// DefaultStructuredRetries is how many times ChatStructured re-prompts the model
+// after an answer that fails validation.
+const DefaultStructuredRetries = 2
+
+// ErrInvalidStructuredOutput is returned by ChatStructured when the model has not
+// produced a valid answer after all retries.
+var ErrInvalidStructuredOutput = errors.New("invalid structured output")
+
+// StructuredOptions configure ChatStructured.
+type StructuredOptions struct {
+	// Retries is how many times an invalid answer is sent back to the model with its
+	// validation error; a negative value disables retrying.
+	Retries     int
+	CallOptions []CallOption
+}
+
+// StructuredOption is a functional option for ChatStructured.
+type StructuredOption func(*StructuredOptions)
+
+// WithRetries sets how many times ChatStructured re-prompts after an invalid answer.
+func WithRetries(n int) StructuredOption {
+	return func(o *StructuredOptions) {
+		o.Retries = n
+	}
+}
+
+// WithStructuredCallOptions passes call options, such as WithCallTools, to every
+// call ChatStructured makes.
+func WithStructuredCallOptions(opts ...CallOption) StructuredOption {
+	return func(o *StructuredOptions) {
+		o.CallOptions = append(o.CallOptions, opts...)
+	}
+}
+
+// ChatStructured asks agent for an answer of type T. The JSON schema of T is derived
+// from its json and jsonschema tags, as for tool inputs, and sent along with msg and,
+// on backends with a JSON mode, as the response format. The answer is validated
+// against it like HTTP tool arguments and unmarshalled, and an invalid answer is sent
+// back to the model with the validation error up to the configured retries. The
+// exchange is part of the session's history like any other turn.
+func ChatStructured[T any](ctx context.Context, agent Agent, msg string, opts ...StructuredOption) (T, error) {
+	var result T
+	o := &StructuredOptions{Retries: DefaultStructuredRetries}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	js, schema, err := structuredSchema[T]()
+	if err != nil {
+		return result, err
+	}
+	encoded, err := json.MarshalIndent(js, "", "  ")
+	if err != nil {
+		return result, fmt.Errorf("failed to encode schema: %v", err)
+	}
+
+	// 支持 JSON 模式的后端同时通过 response format 约束输出，提示词对所有后端生效
+	callOpts := append(slices.Clip(o.CallOptions), WithResponseSchema(js))
+	prompt := fmt.Sprintf("%s\n\n请只输出一个符合以下 JSON Schema 的 JSON 值，不要输出解释或其他内容：\n```json\n%s\n```", msg, encoded)
+	for attempt := 0; ; attempt++ {
+		answer, err := agent.Chat(ctx, prompt, callOpts...)
+		if err != nil {
+			return result, err
+		}
+		err = decodeStructured(answer, schema, &result)
+		if err == nil {
+			return result, nil
+		}
+		if attempt >= o.Retries {
+			return result, fmt.Errorf("%w after %d attempts: %v", ErrInvalidStructuredOutput, attempt+1, err)
+		}
+		log.Printf("[Structured] attempt %d failed validation: %v", attempt+1, err)
+		prompt = fmt.Sprintf("上一次的回答没有通过校验：%v\n请修正后重新输出，只输出符合 JSON Schema 的 JSON。", err)
+	}
+}
+
+// structuredSchema derives the JSON schema of T, both as sent to the model and in
+// the JSON form validateValue reads, which tool parameters share.
+func structuredSchema[T any]() (*jsonschema.Schema, map[string]any, error) {
+	params, err := utils.GoStruct2ParamsOneOf[T]()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive schema: %v", err)
+	}
+	js, err := params.ToJSONSchema()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive schema: %v", err)
+	}
+	data, err := json.Marshal(js)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode schema: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode schema: %v", err)
+	}
+	return js, schema, nil
+}
+
+// decodeStructured extracts the JSON value from an answer, validates it against
+// schema and unmarshals it into v.
+func decodeStructured(answer string, schema map[string]any, v any) error {
+	data := extractJSON(answer)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("not valid JSON: %v", err)
+	}
+	if dec.More() {
+		return fmt.Errorf("not valid JSON: unexpected content after the value")
+	}
+	if doc == nil {
+		return fmt.Errorf("$: must be a JSON value, got null")
+	}
+	if err := validateValue(schema, doc, "$"); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("cannot unmarshal: %v", err)
+	}
+	return nil
+}
+
+// extractJSON strips a Markdown code fence or text around the outermost JSON object
+// or array of an answer.
+func extractJSON(answer string) []byte {
+	s := strings.TrimSpace(answer)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:] // 语言标记，例如 json
+		}
+		if i := strings.LastIndex(s, "```"); i >= 0 {
+			s = s[:i]
+		}
+		return []byte(strings.TrimSpace(s))
+	}
+	if start := strings.IndexAny(s, "{["); start > 0 {
+		if end := strings.LastIndexAny(s, "}]"); end > start {
+			s = s[start : end+1]
+		}
+	}
+	return []byte(s)
+}
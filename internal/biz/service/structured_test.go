@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type weatherReport struct {
+	City        string   `json:"city" jsonschema:"description=城市名"`
+	Temperature int      `json:"temperature" jsonschema:"minimum=-60,maximum=60"`
+	Condition   string   `json:"condition" jsonschema:"enum=晴,enum=阴,enum=雨"`
+	Tips        []string `json:"tips,omitempty"`
+	Humidity    *float64 `json:"humidity,omitempty"`
+}
+
+func TestDecodeStructured(t *testing.T) {
+	_, schema, err := structuredSchema[weatherReport]()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		answer string
+		expect string // 期望的错误片段，为空表示成功
+	}{
+		{`{"city":"北京","temperature":20,"condition":"晴"}`, ""},
+		{"```json\n{\"city\":\"北京\",\"temperature\":20,\"condition\":\"晴\",\"tips\":[\"带伞\"]}\n```", ""},
+		{`结果如下：{"city":"北京","temperature":20,"condition":"晴","humidity":null}。`, ""},
+		{`北京今天晴`, "not valid JSON"},
+		{`{"city":"北京","temperature":20}`, "$: condition is required"},
+		{`{"city":"北京","temperature":"20","condition":"晴"}`, "$.temperature: must be integer"},
+		{`{"city":"北京","temperature":20.5,"condition":"晴"}`, "$.temperature: must be integer"},
+		{`{"city":"北京","temperature":99,"condition":"晴"}`, "$.temperature: must be <= 60"},
+		{`{"city":"北京","temperature":20,"condition":"雪"}`, "$.condition: must be one of"},
+		{`{"city":"北京","temperature":20,"condition":"晴","tips":[1]}`, "$.tips[0]: must be string"},
+		{`{"city":"北京","temperature":20,"condition":"晴","wind":3}`, "$: unknown property wind"},
+		{`{"city":"北京","temperature":20,"condition":"晴"} {}`, "unexpected content"},
+		{`null`, "$: must be a JSON value"},
+	}
+	for _, c := range cases {
+		var r weatherReport
+		err := decodeStructured(c.answer, schema, &r)
+		if c.expect == "" {
+			if err != nil || r.City != "北京" || r.Temperature != 20 {
+				t.Errorf("%s: expect success, but got %+v %v", c.answer, r, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.expect) {
+			t.Errorf("%s: expect error %q, but got %v", c.answer, c.expect, err)
+		}
+	}
+}
+
+func TestChatStructured(t *testing.T) {
+	ctx := context.Background()
+	script := &MockScript{Replies: []MockReply{
+		{Content: "北京今天晴，20 度"},
+		{Content: `{"city":"北京","temperature":20}`},
+		{Content: "```json\n{\"city\":\"北京\",\"temperature\":20,\"condition\":\"晴\"}\n```"},
+	}}
+	store := NewMemorySessionStore(0, 0)
+
+	agent, _ := NewMock("structured", ctx, &AgentOptions{Mock: script, Store: store})
+	r, err := ChatStructured[weatherReport](ctx, agent, "北京天气如何")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.City != "北京" || r.Condition != "晴" {
+		t.Errorf("unexpected report %+v", r)
+	}
+	sess, _ := store.Load("structured")
+	if len(sess.History) != 6 {
+		t.Fatalf("expect %d messages for 3 attempts, but got %d", 6, len(sess.History))
+	}
+	if !strings.Contains(sess.History[0].Content, `"temperature"`) {
+		t.Errorf("expect the schema in the prompt, but got %q", sess.History[0].Content)
+	}
+	if !strings.Contains(sess.History[4].Content, "$: condition is required") {
+		t.Errorf("expect the validation error in the retry, but got %q", sess.History[4].Content)
+	}
+
+	agent, _ = NewMock("structured-fail", ctx, &AgentOptions{Mock: script, Store: store})
+	_, err = ChatStructured[weatherReport](ctx, agent, "北京天气如何", WithRetries(1))
+	if !errors.Is(err, ErrInvalidStructuredOutput) {
+		t.Errorf("expect %v, but got %v", ErrInvalidStructuredOutput, err)
+	}
+}
+
+func TestChatStructured_ResponseFormat(t *testing.T) {
+	var formats []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResponseFormat map[string]any `json:"response_format"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+			return
+		}
+		formats = append(formats, req.ResponseFormat)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "chatcmpl-1", "object": "chat.completion", "created": 1, "model": "stub",
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{
+				"role": "assistant", "content": `{"city":"北京","temperature":20,"condition":"晴"}`,
+			}}},
+		})
+	}))
+	defer srv.Close()
+	t.Setenv("ARK_API_KEY", "test")
+
+	ctx := context.Background()
+	for _, agentType := range []AgentType{OpenAIAgent, DouBaoAgent} {
+		formats = nil
+		agent, err := NewAgent(agentType, "structured-"+string(agentType), ctx,
+			WithBaseURL(srv.URL+"/v1"),
+			WithModelID("stub"),
+			WithSessionStore(NewMemorySessionStore(0, 0)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ChatStructured[weatherReport](ctx, agent, "北京天气如何"); err != nil {
+			t.Fatalf("%s: %v", agentType, err)
+		}
+		if _, err := agent.Chat(ctx, "谢谢"); err != nil {
+			t.Fatalf("%s: %v", agentType, err)
+		}
+
+		if len(formats) != 2 {
+			t.Fatalf("%s: expect %d requests, but got %d", agentType, 2, len(formats))
+		}
+		if formats[0]["type"] != "json_schema" {
+			t.Fatalf("%s: expect a json_schema response format, but got %v", agentType, formats[0])
+		}
+		spec, _ := formats[0]["json_schema"].(map[string]any)
+		js, _ := spec["schema"].(map[string]any)
+		if props, _ := js["properties"].(map[string]any); props["temperature"] == nil {
+			t.Errorf("%s: expect the schema of the answer, but got %v", agentType, spec)
+		}
+		if formats[1] != nil {
+			t.Errorf("%s: expect no response format on a plain chat, but got %v", agentType, formats[1])
+		}
+	}
+}
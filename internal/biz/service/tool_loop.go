@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	DefaultMaxIterations = 8
+	DefaultToolTimeout   = 30 * time.Second
+)
+
+// ErrMaxIterations is returned when the model keeps calling tools past the loop's cap.
+var ErrMaxIterations = errors.New("tool loop exceeded max iterations")
+
+// ToolLoop runs the "generate, call tools, feed results back" cycle shared by every
+// Agent backend. Tool calls from one model response are independent of each other
+// and run in parallel.
+type ToolLoop struct {
+	Tools         map[string]tool.InvokableTool
+	MaxIterations int
+	ToolTimeout   time.Duration
+	OnEvent       EventHandler
+	// Approver decides on calls to tools marked with RequireApproval.
+	Approver        Approver
+	ApprovalTimeout time.Duration
+	// CheckBudget, if set, is consulted before every model call with the tokens the
+	// turn has used so far; an error ends the turn.
+	CheckBudget func(spent Usage) error
+	// ModelOptions are passed to every model call.
+	ModelOptions []model.Option
+}
+
+// NewToolLoop creates a loop over tools configured from opts.
+func NewToolLoop(tools map[string]tool.InvokableTool, opts *AgentOptions) *ToolLoop {
+	l := &ToolLoop{
+		Tools:         tools,
+		MaxIterations: opts.MaxIterations,
+		ToolTimeout:   opts.ToolTimeout,
+		OnEvent:       opts.OnEvent,
+
+		Approver:        opts.Approver,
+		ApprovalTimeout: opts.ApprovalTimeout,
+	}
+	if l.ApprovalTimeout <= 0 {
+		l.ApprovalTimeout = DefaultApprovalTimeout
+	}
+	if l.MaxIterations <= 0 {
+		l.MaxIterations = DefaultMaxIterations
+	}
+	if l.ToolTimeout <= 0 {
+		l.ToolTimeout = DefaultToolTimeout
+	}
+	return l
+}
+
+// Generate calls m until it answers without tool calls. It returns every message
+// produced during the turn, the final answer last; on error the messages produced
+// so far are returned alongside it.
+func (l *ToolLoop) Generate(ctx context.Context, m model.BaseChatModel, history []*schema.Message) ([]*schema.Message, error) {
+	var produced []*schema.Message
+	for i := 0; i < l.MaxIterations; i++ {
+		if err := l.checkBudget(produced); err != nil {
+			return produced, err
+		}
+		input := append(history[:len(history):len(history)], produced...)
+		resp, err := m.Generate(ctx, input, l.ModelOptions...)
+		if err != nil {
+			log.Printf("[ToolLoop] Generate error: %v", err)
+			return produced, err
+		}
+		produced = append(produced, resp)
+		log.Printf("[ToolLoop] model response: content=%s, tool_calls=%d", resp.Content, len(resp.ToolCalls))
+
+		if len(resp.ToolCalls) == 0 {
+			return produced, nil
+		}
+		l.emitThinking(resp)
+		produced = append(produced, l.RunTools(ctx, resp.ToolCalls)...)
+	}
+	return produced, ErrMaxIterations
+}
+
+// ErrStreamClosed is passed to a Stream's onDone when the receiver closed the answer
+// stream before it was drained.
+var ErrStreamClosed = errors.New("stream closed by receiver")
+
+// Stream runs tool rounds until the model starts answering with text and returns
+// that answer as a stream. Tool calls the model makes after its first text still run,
+// and its next answer follows on the same stream. onDone is called exactly once when
+// the turn ends, with every message produced during it: after the stream is drained,
+// with the concatenated answer last and a nil error, or with the error that ended the
+// turn, whether Stream returns it or the stream fails or is closed early.
+func (l *ToolLoop) Stream(ctx context.Context, m model.BaseChatModel, history []*schema.Message,
+	onDone func(produced []*schema.Message, err error)) (*schema.StreamReader[*schema.Message], error) {
+	if onDone == nil {
+		onDone = func([]*schema.Message, error) {}
+	}
+	sr, produced, err := l.stream(ctx, m, history, onDone)
+	if err != nil {
+		onDone(produced, err)
+		return nil, err
+	}
+	return sr, nil
+}
+
+func (l *ToolLoop) stream(ctx context.Context, m model.BaseChatModel, history []*schema.Message,
+	onDone func([]*schema.Message, error)) (*schema.StreamReader[*schema.Message], []*schema.Message, error) {
+	var produced []*schema.Message
+	for i := 0; i < l.MaxIterations; i++ {
+		if err := l.checkBudget(produced); err != nil {
+			return nil, produced, err
+		}
+		input := append(history[:len(history):len(history)], produced...)
+		reader, err := m.Stream(ctx, input, l.ModelOptions...)
+		if err != nil {
+			log.Printf("[ToolLoop] Stream error: %v", err)
+			return nil, produced, err
+		}
+
+		// 持续读取直到发现内容或工具调用
+		var peeked []*schema.Message
+		var first *schema.Message
+		for first == nil {
+			chunk, err := reader.Recv()
+			if err != nil {
+				reader.Close()
+				if errors.Is(err, io.EOF) && len(peeked) > 0 {
+					return l.forward(ctx, m, history, produced, i, peeked, nil, onDone), nil, nil
+				}
+				return nil, produced, err
+			}
+			peeked = append(peeked, chunk)
+			if chunk.Content != "" || len(chunk.ToolCalls) > 0 {
+				first = chunk
+			}
+		}
+		log.Printf("[ToolLoop] meaningful msg found: content=%s, tool_calls=%d", first.Content, len(first.ToolCalls))
+
+		// 普通文本回复：按顺序转发已读到的块和剩余的 reader
+		if len(first.ToolCalls) == 0 {
+			return l.forward(ctx, m, history, produced, i, peeked, reader, onDone), nil, nil
+		}
+
+		// 工具调用：消费剩余流以聚合完整的工具参数
+		for {
+			chunk, err := reader.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				reader.Close()
+				return nil, produced, err
+			}
+			peeked = append(peeked, chunk)
+		}
+		reader.Close()
+		full, err := schema.ConcatMessages(peeked)
+		if err != nil {
+			return nil, produced, fmt.Errorf("failed to concat tool call chunks: %v", err)
+		}
+		produced = append(produced, full)
+		l.emitThinking(full)
+		produced = append(produced, l.RunTools(ctx, full.ToolCalls)...)
+	}
+	return nil, produced, ErrMaxIterations
+}
+
+func (l *ToolLoop) checkBudget(produced []*schema.Message) error {
+	if l.CheckBudget == nil {
+		return nil
+	}
+	return l.CheckBudget(turnUsage(produced))
+}
+
+// forward streams peeked followed by the rest of sr (which may be nil), the answer
+// of the iteration-th model call. If that answer turns out to call tools, they run
+// and the model's next answer is streamed as well. onDone is called once the turn
+// ends, fails or the stream is closed by the receiver.
+func (l *ToolLoop) forward(ctx context.Context, m model.BaseChatModel, history, produced []*schema.Message,
+	iteration int, peeked []*schema.Message, sr *schema.StreamReader[*schema.Message],
+	onDone func([]*schema.Message, error)) *schema.StreamReader[*schema.Message] {
+	out, w := schema.Pipe[*schema.Message](8)
+	go func() {
+		defer w.Close()
+		var err error
+		// 先保存本轮结果再结束输出流，读到 EOF 或错误时会话已写入
+		defer func() {
+			onDone(produced, err)
+			if err != nil && !errors.Is(err, ErrStreamClosed) {
+				w.Send(nil, err)
+			}
+		}()
+
+		for {
+			var msg *schema.Message
+			msg, err = relay(peeked, sr, w)
+			if msg != nil {
+				produced = append(produced, msg)
+			}
+			if err != nil {
+				return
+			}
+			if len(msg.ToolCalls) == 0 {
+				return
+			}
+
+			// 文本之后又发起了工具调用：执行工具后继续在同一个流上回答
+			log.Printf("[ToolLoop] tool calls after text: %d", len(msg.ToolCalls))
+			produced = append(produced, l.RunTools(ctx, msg.ToolCalls)...)
+			if iteration++; iteration >= l.MaxIterations {
+				err = ErrMaxIterations
+			} else {
+				err = l.checkBudget(produced)
+			}
+			if err == nil {
+				input := append(history[:len(history):len(history)], produced...)
+				sr, err = m.Stream(ctx, input, l.ModelOptions...)
+			}
+			if err != nil {
+				log.Printf("[ToolLoop] Stream error: %v", err)
+				return
+			}
+			peeked = nil
+		}
+	}()
+	return out
+}
+
+// relay sends peeked and then the rest of sr, which it closes, to w and returns
+// them concatenated. Tool calls are left out of the sent chunks: they are only
+// complete once concatenated, and the loop runs them.
+func relay(peeked []*schema.Message, sr *schema.StreamReader[*schema.Message], w *schema.StreamWriter[*schema.Message]) (*schema.Message, error) {
+	if sr != nil {
+		defer sr.Close()
+	}
+	send := func(chunk *schema.Message) bool {
+		if len(chunk.ToolCalls) > 0 {
+			c := *chunk
+			c.ToolCalls = nil
+			chunk = &c
+		}
+		return w.Send(chunk, nil)
+	}
+
+	chunks := append([]*schema.Message(nil), peeked...)
+	var err error
+	for _, chunk := range peeked {
+		if closed := send(chunk); closed {
+			err = ErrStreamClosed
+			break
+		}
+	}
+	for sr != nil && err == nil {
+		chunk, recvErr := sr.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			err = recvErr
+			break
+		}
+		chunks = append(chunks, chunk)
+		if closed := send(chunk); closed {
+			err = ErrStreamClosed
+		}
+	}
+
+	msg, concatErr := schema.ConcatMessages(chunks)
+	if concatErr != nil {
+		log.Printf("[ToolLoop] concat messages error: %v", concatErr)
+		if err == nil {
+			err = concatErr
+		}
+		return nil, err
+	}
+	return msg, err
+}
+
+// RunTools executes calls in parallel and returns one ToolMessage per call, in order.
+// Failures and rejected approvals are reported to the model as tool results rather
+// than aborting the turn.
+func (l *ToolLoop) RunTools(ctx context.Context, calls []schema.ToolCall) []*schema.Message {
+	results := make([]*schema.Message, len(calls))
+	var wg sync.WaitGroup
+	for i, tc := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if t, ok := l.Tools[tc.Function.Name]; ok && requiresApproval(t) {
+				if reason := l.approve(ctx, tc); reason != "" {
+					log.Printf("[ToolLoop] tool %s rejected: %s", tc.Function.Name, reason)
+					l.emit(toolResultEvent(tc.ID, tc.Function.Name, "", fmt.Errorf("rejected: %s", reason), 0))
+					results[i] = schema.ToolMessage(toolRejectedResult(tc.Function.Name, reason), tc.ID, schema.WithToolName(tc.Function.Name))
+					return
+				}
+			}
+			l.emit(AgentEvent{
+				Event:      EventToolCallStart,
+				ToolCallID: tc.ID,
+				ToolName:   tc.Function.Name,
+				Arguments:  tc.Function.Arguments,
+			})
+			start := time.Now()
+			res, err := l.runTool(ctx, tc)
+			l.emit(toolResultEvent(tc.ID, tc.Function.Name, res, err, time.Since(start)))
+			if err != nil {
+				log.Printf("[ToolLoop] tool %s error: %v", tc.Function.Name, err)
+				res = toolErrorResult(tc.Function.Name, err)
+			} else {
+				log.Printf("[ToolLoop] tool %s result: %s", tc.Function.Name, res)
+			}
+			results[i] = schema.ToolMessage(res, tc.ID, schema.WithToolName(tc.Function.Name))
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runTool invokes the tool of tc. A panicking tool fails the call instead of the
+// process, since it runs on its own goroutine.
+func (l *ToolLoop) runTool(ctx context.Context, tc schema.ToolCall) (res string, err error) {
+	log.Printf("[ToolLoop] calling tool: %s, args: %s", tc.Function.Name, tc.Function.Arguments)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ToolLoop] tool %s panicked: %v\n%s", tc.Function.Name, r, debug.Stack())
+			res, err = "", fmt.Errorf("panic: %v", r)
+		}
+	}()
+	t, ok := l.Tools[tc.Function.Name]
+	if !ok {
+		return "", fmt.Errorf("tool not found")
+	}
+	args := tc.Function.Arguments
+	if args == "" {
+		args = "{}"
+	}
+	if l.ToolTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.ToolTimeout)
+		defer cancel()
+	}
+	return t.InvokableRun(ctx, args)
+}
+
+func (l *ToolLoop) emit(ev AgentEvent) {
+	if l.OnEvent != nil {
+		l.OnEvent(ev)
+	}
+}
+
+// emitThinking reports the reasoning or preamble the model produced before calling tools.
+func (l *ToolLoop) emitThinking(msg *schema.Message) {
+	if thought := msg.ReasoningContent + msg.Content; thought != "" {
+		l.emit(AgentEvent{Event: EventThinking, Content: thought})
+	}
+}
+
+// toolErrorResult tells the model about a failed call: invalid arguments are for it
+// to fix, other failures are not worth retrying.
+func toolErrorResult(name string, err error) string {
+	if errors.Is(err, ErrInvalidArguments) {
+		return fmt.Sprintf("工具 %s 的参数有误: %v。请根据错误信息修正参数后重新调用该工具。", name, err)
+	}
+	return fmt.Sprintf("工具 %s 执行失败: %v。请不要重试该工具，请直接告知用户该功能暂时不可用，并尝试用你已有的知识回答或表示歉意。", name, err)
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+// scriptedModel returns its responses in order, repeating the last one. Streamed
+// responses send their text rune by rune, then their tool calls.
+type scriptedModel struct {
+	responses []*schema.Message
+	calls     int
+}
+
+func (s *scriptedModel) next() *schema.Message {
+	resp := s.responses[min(s.calls, len(s.responses)-1)]
+	s.calls++
+	return resp
+}
+
+func (s *scriptedModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return s.next(), nil
+}
+
+func (s *scriptedModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	resp := s.next()
+	// 文本逐字发送，工具调用在其后的一个块中
+	var chunks []*schema.Message
+	for _, r := range resp.Content {
+		chunks = append(chunks, schema.AssistantMessage(string(r), nil))
+	}
+	if len(resp.ToolCalls) > 0 {
+		chunks = append(chunks, schema.AssistantMessage("", resp.ToolCalls))
+	}
+	// 与真实模型一样，用量随最后一个块返回
+	if len(chunks) > 0 {
+		chunks[len(chunks)-1].ResponseMeta = resp.ResponseMeta
+	}
+	return schema.StreamReaderFromArray(chunks), nil
+}
+
+func (s *scriptedModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return s, nil
+}
+
+func sleepTool(name string, d time.Duration) tool.InvokableTool {
+	return utils.NewTool[struct{}, string](
+		&schema.ToolInfo{Name: name, Desc: name},
+		func(ctx context.Context, _ struct{}) (string, error) {
+			select {
+			case <-time.After(d):
+				return name + " done", nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		},
+	)
+}
+
+func toolCalls(names ...string) *schema.Message {
+	calls := make([]schema.ToolCall, 0, len(names))
+	for _, name := range names {
+		calls = append(calls, schema.ToolCall{ID: "call-" + name, Function: schema.FunctionCall{Name: name}})
+	}
+	return schema.AssistantMessage("", calls)
+}
+
+func TestToolLoop_MaxIterations(t *testing.T) {
+	loop := NewToolLoop(map[string]tool.InvokableTool{"a": sleepTool("a", 0)}, &AgentOptions{MaxIterations: 3})
+	m := &scriptedModel{responses: []*schema.Message{toolCalls("a")}}
+
+	_, err := loop.Generate(context.Background(), m, nil)
+	if !errors.Is(err, ErrMaxIterations) {
+		t.Errorf("expect %v, but got %v", ErrMaxIterations, err)
+	}
+	if m.calls != 3 {
+		t.Errorf("expect %d model calls, but got %d", 3, m.calls)
+	}
+}
+
+func TestToolLoop_RunTools(t *testing.T) {
+	loop := NewToolLoop(map[string]tool.InvokableTool{
+		"a":    sleepTool("a", 50*time.Millisecond),
+		"b":    sleepTool("b", 50*time.Millisecond),
+		"slow": sleepTool("slow", time.Second),
+		"panic": utils.NewTool[struct{}, string](&schema.ToolInfo{Name: "panic"}, func(ctx context.Context, _ struct{}) (string, error) {
+			panic("boom")
+		}),
+	}, &AgentOptions{ToolTimeout: 80 * time.Millisecond})
+
+	start := time.Now()
+	results := loop.RunTools(context.Background(), toolCalls("a", "b", "slow", "missing", "panic").ToolCalls)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expect tools to run in parallel, but took %s", elapsed)
+	}
+
+	if len(results) != 5 {
+		t.Fatalf("expect %d results, but got %d", 5, len(results))
+	}
+	if results[0].Content != "a done" || results[1].Content != "b done" {
+		t.Errorf("expect results in call order, but got %q %q", results[0].Content, results[1].Content)
+	}
+	if !strings.Contains(results[2].Content, "deadline exceeded") {
+		t.Errorf("expect timeout error, but got %q", results[2].Content)
+	}
+	if !strings.Contains(results[3].Content, "tool not found") || results[3].ToolCallID != "call-missing" {
+		t.Errorf("expect not found error for call-missing, but got %+v", results[3])
+	}
+	// a panicking tool fails its call without taking the process down
+	if !strings.Contains(results[4].Content, "panic: boom") || strings.Contains(results[4].Content, "goroutine") {
+		t.Errorf("expect the panic as a tool error, but got %q", results[4].Content)
+	}
+}
+
+func TestToolLoop_Stream(t *testing.T) {
+	loop := NewToolLoop(map[string]tool.InvokableTool{"a": sleepTool("a", 0)}, &AgentOptions{})
+	m := &scriptedModel{responses: []*schema.Message{toolCalls("a"), schema.AssistantMessage("hello", nil)}}
+
+	var produced []*schema.Message
+	reader, err := loop.Stream(context.Background(), m, nil, func(msgs []*schema.Message, err error) {
+		produced = msgs
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content string
+	for {
+		chunk, err := reader.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content += chunk.Content
+	}
+	if content != "hello" {
+		t.Errorf("expect %q, but got %q", "hello", content)
+	}
+	// assistant(tool call), tool result, final answer
+	if len(produced) != 3 || produced[2].Content != "hello" {
+		t.Errorf("expect 3 produced messages ending with the answer, but got %v", produced)
+	}
+}
+
+func TestToolLoop_StreamToolCallsAfterText(t *testing.T) {
+	loop := NewToolLoop(map[string]tool.InvokableTool{"a": sleepTool("a", 0)}, &AgentOptions{})
+	check := toolCalls("a")
+	check.Content = "Let me check. "
+	m := &scriptedModel{responses: []*schema.Message{check, schema.AssistantMessage("hello", nil)}}
+
+	var produced []*schema.Message
+	reader, err := loop.Stream(context.Background(), m, nil, func(msgs []*schema.Message, err error) {
+		if err != nil {
+			t.Errorf("expect no error, but got %v", err)
+		}
+		produced = msgs
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content string
+	for {
+		chunk, err := reader.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk.ToolCalls) > 0 {
+			t.Errorf("expect no tool calls in the answer stream, but got %v", chunk.ToolCalls)
+		}
+		content += chunk.Content
+	}
+	if content != "Let me check. hello" {
+		t.Errorf("expect %q, but got %q", "Let me check. hello", content)
+	}
+	// assistant(text and tool call), tool result, final answer
+	if len(produced) != 3 || len(produced[0].ToolCalls) != 1 || produced[1].Role != schema.Tool ||
+		produced[1].Content != "a done" || produced[2].Content != "hello" {
+		t.Errorf("expect the tool call answered before the final answer, but got %v", produced)
+	}
+
+	// 工具调用之后的轮次同样受 MaxIterations 限制
+	loop.MaxIterations = 1
+	m = &scriptedModel{responses: []*schema.Message{check}}
+	var doneErr error
+	reader, err = loop.Stream(context.Background(), m, nil, func(msgs []*schema.Message, err error) {
+		produced, doneErr = msgs, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err = reader.Recv(); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrMaxIterations) || !errors.Is(doneErr, ErrMaxIterations) {
+		t.Errorf("expect %v, but got %v and %v", ErrMaxIterations, err, doneErr)
+	}
+	if len(produced) != 2 || produced[1].Role != schema.Tool {
+		t.Errorf("expect every tool call answered, but got %v", produced)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+
+	"goplayground/pkg/middleware"
+)
+
+// ErrInvalidArguments marks tool errors caused by the model's arguments. They are
+// fed back to the model as is: not retried, not replaced by a fallback and not
+// counted against the circuit breaker.
+var ErrInvalidArguments = errors.New("invalid arguments")
+
+// ToolPolicy configures the resilience layer around a tool. The zero value leaves
+// the tool unwrapped.
+type ToolPolicy struct {
+	// Fallback is returned in place of an error, e.g. a canned answer.
+	Fallback string
+	// Retries is the number of extra attempts after a failure, RetryBackoff the delay
+	// before the first one (doubled each time).
+	Retries      int
+	RetryBackoff time.Duration
+	// CacheTTL caches successful results by normalized arguments.
+	CacheTTL time.Duration
+	// CircuitThreshold consecutive failures stop calls to the tool for
+	// CircuitCooldown.
+	CircuitThreshold int
+	CircuitCooldown  time.Duration
+}
+
+func (p ToolPolicy) empty() bool {
+	return p == ToolPolicy{}
+}
+
+// resilientTool runs a tool through a middleware chain built from its ToolPolicy.
+type resilientTool struct {
+	tool.InvokableTool
+	manager *middleware.Manager[string, string]
+}
+
+// WrapTool decorates t with p. From the outside in: fallback, cache, circuit breaker,
+// retries and panic recovery, so a cached result skips an open circuit, a call that
+// fails after all retries counts as a single failure and a panic is handled like
+// any other failure.
+func WrapTool(t tool.InvokableTool, p ToolPolicy) tool.InvokableTool {
+	if p.empty() {
+		return t
+	}
+	m := middleware.NewManager[string, string]()
+	if p.Fallback != "" {
+		m.Register(middleware.Plugin[string, string]{
+			Name: "Fallback",
+			Action: middleware.Fallback(func(ctx context.Context, args string) (string, error) {
+				return p.Fallback, nil
+			}, isToolFailure),
+		})
+	}
+	if p.CacheTTL > 0 {
+		m.Register(middleware.Plugin[string, string]{
+			Name:   "Cache",
+			Action: middleware.Cache[string, string](p.CacheTTL, normalizeArguments),
+		})
+	}
+	if p.CircuitThreshold > 0 {
+		cooldown := p.CircuitCooldown
+		if cooldown <= 0 {
+			cooldown = 30 * time.Second
+		}
+		m.Register(middleware.Plugin[string, string]{
+			Name:   "CircuitBreaker",
+			Action: middleware.CircuitBreak[string, string](middleware.NewCircuitBreaker(p.CircuitThreshold, cooldown), isToolFailure),
+		})
+	}
+	if p.Retries > 0 {
+		backoff := p.RetryBackoff
+		if backoff <= 0 {
+			backoff = 200 * time.Millisecond
+		}
+		m.Register(middleware.Plugin[string, string]{
+			Name: "Retry",
+			Action: middleware.Retry[string, string](middleware.RetryOptions{
+				Attempts:   p.Retries + 1,
+				Backoff:    backoff,
+				MaxBackoff: 5 * time.Second,
+				Retryable:  isToolFailure,
+			}),
+		})
+	}
+	m.Register(middleware.Plugin[string, string]{
+		Name:   "Recovery",
+		Action: middleware.Recovery[string, string](),
+	})
+	return &resilientTool{InvokableTool: t, manager: m}
+}
+
+func (t *resilientTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return t.manager.Run(ctx, argumentsInJSON, func(ctx context.Context, args string) (string, error) {
+		return t.InvokableTool.InvokableRun(ctx, args, opts...)
+	})
+}
+
+// isToolFailure reports whether err is the tool's fault rather than the caller's.
+func isToolFailure(err error) bool {
+	return !errors.Is(err, ErrInvalidArguments) && !errors.Is(err, context.Canceled)
+}
+
+// normalizeArguments re-encodes JSON arguments with sorted keys and no whitespace,
+// so equivalent calls share a cache entry.
+func normalizeArguments(args string) (string, bool) {
+	if args == "" {
+		return "{}", true
+	}
+	var v any
+	if err := json.Unmarshal([]byte(args), &v); err != nil {
+		return "", false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+
+	"goplayground/pkg/middleware"
+)
+
+// countingTool fails while failing is set and counts its calls.
+type countingTool struct {
+	calls   int
+	failing bool
+	err     error
+}
+
+func (c *countingTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "counting"}, nil
+}
+
+func (c *countingTool) InvokableRun(ctx context.Context, args string, opts ...tool.Option) (string, error) {
+	c.calls++
+	if c.failing {
+		return "", c.err
+	}
+	return fmt.Sprintf("result %d", c.calls), nil
+}
+
+func TestWrapTool(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingTool{}
+	wrapped := WrapTool(inner, ToolPolicy{
+		Fallback:         "兜底",
+		Retries:          2,
+		RetryBackoff:     time.Millisecond,
+		CacheTTL:         time.Minute,
+		CircuitThreshold: 2,
+		CircuitCooldown:  time.Minute,
+	})
+
+	// equivalent arguments share a cache entry
+	first, _ := wrapped.InvokableRun(ctx, `{"a": 1, "b": 2}`)
+	second, _ := wrapped.InvokableRun(ctx, `{"b":2,"a":1}`)
+	if first != "result 1" || second != first || inner.calls != 1 {
+		t.Errorf("expect cached result, but got %s, %s after %d calls", first, second, inner.calls)
+	}
+
+	// a failure is retried, then replaced by the fallback
+	inner.failing, inner.err, inner.calls = true, errors.New("unavailable"), 0
+	if out, err := wrapped.InvokableRun(ctx, `{"a": 2}`); err != nil || out != "兜底" || inner.calls != 3 {
+		t.Errorf("expect fallback after %d calls, but got %s, %v after %d", 3, out, err, inner.calls)
+	}
+
+	// argument errors go back to the model untouched and do not trip the circuit
+	inner.err, inner.calls = fmt.Errorf("%w: latitude: must be <= 90", ErrInvalidArguments), 0
+	if _, err := wrapped.InvokableRun(ctx, `{"a": 3}`); !errors.Is(err, ErrInvalidArguments) || inner.calls != 1 {
+		t.Errorf("expect invalid arguments after 1 call, but got %v after %d", err, inner.calls)
+	}
+
+	// a second tool failure opens the circuit; calls stop but the fallback still answers
+	inner.err, inner.calls = errors.New("unavailable"), 0
+	wrapped.InvokableRun(ctx, `{"a": 4}`)
+	if out, _ := wrapped.InvokableRun(ctx, `{"a": 5}`); out != "兜底" || inner.calls != 3 {
+		t.Errorf("expect open circuit after %d calls, but got %s after %d", 3, out, inner.calls)
+	}
+
+	if WrapTool(inner, ToolPolicy{}) != tool.InvokableTool(inner) {
+		t.Errorf("expect zero policy to leave the tool unwrapped")
+	}
+}
+
+func TestWrapTool_CircuitOpenError(t *testing.T) {
+	inner := &countingTool{failing: true, err: errors.New("unavailable")}
+	wrapped := WrapTool(inner, ToolPolicy{CircuitThreshold: 1, CircuitCooldown: time.Minute})
+	wrapped.InvokableRun(context.Background(), `{}`)
+	if _, err := wrapped.InvokableRun(context.Background(), `{}`); !errors.Is(err, middleware.ErrCircuitOpen) {
+		t.Errorf("expect %v, but got %v", middleware.ErrCircuitOpen, err)
+	}
+}
+
+func TestWrapTool_PanicFallback(t *testing.T) {
+	panicking := utils.NewTool[struct{}, string](&schema.ToolInfo{Name: "panicking"}, func(ctx context.Context, _ struct{}) (string, error) {
+		panic("boom")
+	})
+	if out, err := WrapTool(panicking, ToolPolicy{Fallback: "兜底"}).InvokableRun(context.Background(), `{}`); err != nil || out != "兜底" {
+		t.Errorf("expect the fallback for a panic, but got %q, %v", out, err)
+	}
+	_, err := WrapTool(panicking, ToolPolicy{Retries: 1, RetryBackoff: time.Millisecond}).InvokableRun(context.Background(), `{}`)
+	if err == nil || strings.Contains(err.Error(), "goroutine") {
+		t.Errorf("expect the panic as an error without the stack, but got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Tool types accepted in ToolConfig.Type.
+const (
+	ToolTypeHTTP    = "http"
+	ToolTypeSQL     = "sql"
+	ToolTypeBuiltin = "builtin"
+)
+
+// ToolConfig declares one tool, typically loaded from the tools section of configs/.
+type ToolConfig struct {
+	Name        string
+	Type        string
+	Description string
+	// URL and Body are Go templates over the arguments for http tools; Method is
+	// GET, POST or PUT.
+	URL    string
+	Method string
+	Body   string
+	// Extract is a JMESPath expression selecting what an http tool returns from a
+	// JSON response.
+	Extract string
+	// Params declares an http tool's typed arguments; Parameters is the raw JSON
+	// schema alternative for anything Params cannot express.
+	Params     []HTTPParam
+	Parameters map[string]any
+	// Headers are sent with every request; values may reference ${ENV} variables.
+	Headers map[string]string
+	// AllowedHosts limits an http tool to these hosts ("*.example.com" matches
+	// subdomains); empty means the host of URL. AllowedSchemes defaults to https
+	// and http.
+	AllowedHosts   []string
+	AllowedSchemes []string
+	// AllowPrivateNetworks lets an http tool reach private and loopback addresses.
+	AllowPrivateNetworks bool
+	// MaxResponseBytes caps an http tool's response, DefaultMaxResponseBytes if zero.
+	MaxResponseBytes int64
+
+	DSN     string
+	MaxRows int
+
+	// ToolPolicy adds fallback, retries, caching and circuit breaking to any tool.
+	ToolPolicy
+	// RequiresApproval makes the agent ask the user before every call.
+	RequiresApproval bool
+}
+
+// ToolRegistry holds the tools offered to agents, in registration order.
+type ToolRegistry struct {
+	mtx   sync.RWMutex
+	tools []tool.InvokableTool
+	names map[string]int
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{names: make(map[string]int)}
+}
+
+// Register adds t; a tool with the same name replaces the earlier one.
+func (r *ToolRegistry) Register(ctx context.Context, t tool.InvokableTool) error {
+	info, err := t.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get tool info: %v", err)
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if i, ok := r.names[info.Name]; ok {
+		r.tools[i] = t
+		return nil
+	}
+	r.names[info.Name] = len(r.tools)
+	r.tools = append(r.tools, t)
+	return nil
+}
+
+// Get returns the tool registered under name.
+func (r *ToolRegistry) Get(name string) (tool.InvokableTool, bool) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	i, ok := r.names[name]
+	if !ok {
+		return nil, false
+	}
+	return r.tools[i], true
+}
+
+// Select returns the tools registered under names, failing on an unknown one.
+func (r *ToolRegistry) Select(names []string) ([]tool.InvokableTool, error) {
+	tools := make([]tool.InvokableTool, 0, len(names))
+	for _, name := range names {
+		t, ok := r.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown tool: %s", name)
+		}
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
+
+// Tools returns all registered tools.
+func (r *ToolRegistry) Tools() []tool.InvokableTool {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return append([]tool.InvokableTool(nil), r.tools...)
+}
+
+var defaultToolRegistry = NewToolRegistry()
+
+// SetToolRegistry replaces the registry whose tools the handlers give to agents.
+func SetToolRegistry(r *ToolRegistry) {
+	defaultToolRegistry = r
+}
+
+// DefaultToolRegistry returns the registry used by the handlers.
+func DefaultToolRegistry() *ToolRegistry {
+	return defaultToolRegistry
+}
+
+var (
+	builtinToolsMtx sync.RWMutex
+	builtinTools    = map[string]func() tool.InvokableTool{
+		"get_current_time": NewCurrentTimeTool,
+	}
+)
+
+// RegisterBuiltinTool makes a tool implemented in code available to the config as
+// type builtin under name.
+func RegisterBuiltinTool(name string, f func() tool.InvokableTool) {
+	builtinToolsMtx.Lock()
+	defer builtinToolsMtx.Unlock()
+	builtinTools[name] = f
+}
+
+// LoadToolRegistry builds a registry from tool declarations. A database that cannot
+// be opened only disables its tools; any other invalid declaration is an error.
+func LoadToolRegistry(ctx context.Context, configs []ToolConfig) (*ToolRegistry, error) {
+	r := NewToolRegistry()
+	for _, cfg := range configs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("tool name is required")
+		}
+		tools, err := buildTools(cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tools {
+			t = WrapTool(t, cfg.ToolPolicy)
+			if cfg.RequiresApproval {
+				t = RequireApproval(t)
+			}
+			if err := r.Register(ctx, t); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+func buildTools(cfg ToolConfig) ([]tool.InvokableTool, error) {
+	switch cfg.Type {
+	case ToolTypeHTTP:
+		t, err := NewHTTPTool(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []tool.InvokableTool{t}, nil
+	case ToolTypeSQL:
+		db, err := OpenDatabase(cfg.DSN)
+		if err != nil {
+			log.Printf("[Tools] %s disabled: %v", cfg.Name, err)
+			return nil, nil
+		}
+		return []tool.InvokableTool{NewDatabaseTool(cfg.Name, db, cfg.MaxRows), NewSchemaTool(cfg.Name, db)}, nil
+	case ToolTypeBuiltin:
+		builtinToolsMtx.RLock()
+		f, ok := builtinTools[cfg.Name]
+		builtinToolsMtx.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown builtin tool: %s", cfg.Name)
+		}
+		return []tool.InvokableTool{f()}, nil
+	default:
+		return nil, fmt.Errorf("tool %s: unknown type %q", cfg.Name, cfg.Type)
+	}
+}
+
+// paramsOf derives a tool's parameter schema from its request struct's json and
+// jsonschema tags; utils.NewTool does not infer it.
+func paramsOf[T any]() *schema.ParamsOneOf {
+	params, _ := utils.GoStruct2ParamsOneOf[T]()
+	return params
+}
+
+// CurrentTimeRequest is the input schema for the current time tool.
+type CurrentTimeRequest struct {
+	Timezone string `json:"timezone,omitempty" jsonschema:"description=可选：IANA 时区名，例如 Asia/Shanghai，默认为服务器时区"`
+}
+
+// CurrentTimeResponse is the output schema for the current time tool.
+type CurrentTimeResponse struct {
+	Time     string `json:"time"`
+	Timezone string `json:"timezone"`
+	Weekday  string `json:"weekday"`
+}
+
+// NewCurrentTimeTool creates the get_current_time builtin tool.
+func NewCurrentTimeTool() tool.InvokableTool {
+	return utils.NewTool[CurrentTimeRequest, CurrentTimeResponse](
+		&schema.ToolInfo{
+			Name:        "get_current_time",
+			Desc:        "获取当前日期和时间，可指定时区。",
+			ParamsOneOf: paramsOf[CurrentTimeRequest](),
+		},
+		func(ctx context.Context, input CurrentTimeRequest) (CurrentTimeResponse, error) {
+			now := time.Now()
+			if input.Timezone != "" {
+				loc, err := time.LoadLocation(input.Timezone)
+				if err != nil {
+					return CurrentTimeResponse{}, fmt.Errorf("unknown timezone: %s", input.Timezone)
+				}
+				now = now.In(loc)
+			}
+			return CurrentTimeResponse{
+				Time:     now.Format(time.RFC3339),
+				Timezone: now.Location().String(),
+				Weekday:  now.Weekday().String(),
+			}, nil
+		},
+	)
+}
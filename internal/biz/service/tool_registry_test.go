@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadToolRegistry(t *testing.T) {
+	var gotQuery, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+	t.Setenv("TEST_TOOL_TOKEN", "secret")
+
+	tools, err := LoadToolRegistry(context.Background(), []ToolConfig{
+		{
+			Name: "search",
+			Type: ToolTypeHTTP,
+			URL:  srv.URL + "/search?q={{urlquery .q}}&n={{.n}}",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"q": map[string]any{"type": "string"},
+					"n": map[string]any{"type": "integer"},
+				},
+			},
+			Headers:              map[string]string{"Authorization": "Bearer ${TEST_TOOL_TOKEN}"},
+			AllowPrivateNetworks: true,
+		},
+		{Name: "get_current_time", Type: ToolTypeBuiltin},
+		{Name: "missing_db", Type: ToolTypeSQL, DSN: filepath.Join(t.TempDir(), "missing", "x.db")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the sql tool is skipped because its database cannot be opened
+	if n := len(tools.Tools()); n != 2 {
+		t.Errorf("expect %d tools, but got %d", 2, n)
+	}
+
+	for _, tl := range tools.Tools() {
+		if info, _ := tl.Info(context.Background()); info.ParamsOneOf == nil {
+			t.Errorf("expect parameters for %s", info.Name)
+		}
+	}
+
+	search, ok := tools.Get("search")
+	if !ok {
+		t.Fatal("expect search tool")
+	}
+	out, err := search.InvokableRun(context.Background(), `{"q":"a b","n":3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != `{"ok":true}` || gotQuery != "q=a+b&n=3" || gotAuth != "Bearer secret" {
+		t.Errorf("expect rendered request, but got %s %s %s", out, gotQuery, gotAuth)
+	}
+	// an omitted optional argument renders as empty
+	if _, err := search.InvokableRun(context.Background(), `{"q":"a"}`); err != nil || gotQuery != "q=a&n=" {
+		t.Errorf("expect %s, but got %s, %v", "q=a&n=", gotQuery, err)
+	}
+}
+
+func TestLoadToolRegistry_Invalid(t *testing.T) {
+	for _, cfg := range []ToolConfig{
+		{Name: "x", Type: "ftp"},
+		{Name: "nope", Type: ToolTypeBuiltin},
+		{Type: ToolTypeHTTP},
+	} {
+		if _, err := LoadToolRegistry(context.Background(), []ToolConfig{cfg}); err == nil {
+			t.Errorf("expect error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// MaxUploadBytes bounds a single uploaded file.
+	MaxUploadBytes = 10 << 20
+	// DefaultUploadCapacity bounds the bytes kept by the default upload store.
+	DefaultUploadCapacity = 256 << 20
+	// DefaultUploadTTL is how long an upload can be referenced after it was made.
+	DefaultUploadTTL = time.Hour
+)
+
+// ErrUploadNotFound is returned for an unknown or expired upload id.
+var ErrUploadNotFound = errors.New("upload not found")
+
+// Upload is a file uploaded for use as a message attachment.
+type Upload struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	MIMEType  string    `json:"mimeType"`
+	Size      int       `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      []byte    `json:"-"`
+}
+
+// UploadStore keeps uploads in process memory, dropping the oldest once capacity
+// bytes are exceeded and any older than ttl.
+type UploadStore struct {
+	capacity int
+	ttl      time.Duration
+
+	mtx     sync.Mutex
+	size    int
+	order   []string
+	uploads map[string]*Upload
+}
+
+// NewUploadStore creates an upload store. A capacity or ttl <= 0 disables that limit.
+func NewUploadStore(capacity int, ttl time.Duration) *UploadStore {
+	return &UploadStore{capacity: capacity, ttl: ttl, uploads: make(map[string]*Upload)}
+}
+
+// Put stores data and returns the new upload. A missing or generic mimeType is
+// detected from the content.
+func (s *UploadStore) Put(name, mimeType string, data []byte) *Upload {
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	u := &Upload{
+		ID:        randomID(),
+		Name:      name,
+		MIMEType:  mimeType,
+		Size:      len(data),
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.uploads[u.ID] = u
+	s.order = append(s.order, u.ID)
+	s.size += u.Size
+	s.evict(time.Now())
+	return u
+}
+
+// Get returns a live upload.
+func (s *UploadStore) Get(id string) (*Upload, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.evict(time.Now())
+	u, ok := s.uploads[id]
+	if !ok {
+		return nil, ErrUploadNotFound
+	}
+	return u, nil
+}
+
+// evict drops uploads from the oldest on while they are expired or the store is
+// over capacity; the newest upload is always kept.
+func (s *UploadStore) evict(now time.Time) {
+	for len(s.order) > 1 {
+		u := s.uploads[s.order[0]]
+		expired := s.ttl > 0 && now.Sub(u.CreatedAt) > s.ttl
+		if !expired && (s.capacity <= 0 || s.size <= s.capacity) {
+			return
+		}
+		delete(s.uploads, u.ID)
+		s.size -= u.Size
+		s.order = s.order[1:]
+	}
+}
+
+var defaultUploadStore = NewUploadStore(DefaultUploadCapacity, DefaultUploadTTL)
+
+// SetUploadStore replaces the store used by the upload endpoints and attachments.
+func SetUploadStore(store *UploadStore) {
+	defaultUploadStore = store
+}
+
+// DefaultUploadStore returns the store used by the upload endpoints and attachments.
+func DefaultUploadStore() *UploadStore {
+	return defaultUploadStore
+}
+
+// HandleUpload stores the multipart "file" field and returns its id, which messages
+// then reference as an attachment.
+func HandleUpload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxUploadBytes+1<<20)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fh.Size > MaxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file exceeds upload limit"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	u := DefaultUploadStore().Put(fh.Filename, fh.Header.Get("Content-Type"), data)
+	c.JSON(http.StatusCreated, u)
+}
+
+// HandleGetUpload returns the content of an upload.
+func HandleGetUpload(c *gin.Context) {
+	u, err := DefaultUploadStore().Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	// 只有图片内联展示，其余一律下载，避免上传的 HTML 在本站执行
+	c.Header("X-Content-Type-Options", "nosniff")
+	if !strings.HasPrefix(u.MIMEType, "image/") || strings.HasPrefix(u.MIMEType, "image/svg") {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", u.Name))
+	}
+	c.Data(http.StatusOK, u.MIMEType, u.Data)
+}
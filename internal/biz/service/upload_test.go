@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUploadStore_Evict(t *testing.T) {
+	s := NewUploadStore(10, time.Hour)
+	a := s.Put("a", "", []byte("123456"))
+	b := s.Put("b", "", []byte("123456"))
+	if _, err := s.Get(a.ID); err != ErrUploadNotFound {
+		t.Errorf("expect the oldest upload evicted, but got %v", err)
+	}
+	if _, err := s.Get(b.ID); err != nil {
+		t.Errorf("expect the newest upload kept, but got %v", err)
+	}
+
+	s = NewUploadStore(0, time.Millisecond)
+	c := s.Put("c", "", []byte("1"))
+	time.Sleep(5 * time.Millisecond)
+	s.Put("d", "", []byte("2"))
+	if _, err := s.Get(c.ID); err != ErrUploadNotFound {
+		t.Errorf("expect the expired upload evicted, but got %v", err)
+	}
+}
+
+func postFile(t *testing.T, url, name string, data []byte) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fw, _ := w.CreateFormFile("file", name)
+	fw.Write(data)
+	w.Close()
+	resp, err := http.Post(url, w.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHandleUpload(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := postFile(t, srv.URL+"/ai/uploads", "cat.png", pngHeader)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expect %d, but got %d", http.StatusCreated, resp.StatusCode)
+	}
+	var u Upload
+	json.NewDecoder(resp.Body).Decode(&u)
+	if u.ID == "" || u.Name != "cat.png" || u.MIMEType != "image/png" || u.Size != len(pngHeader) {
+		t.Errorf("unexpected upload %+v", u)
+	}
+
+	resp, err := http.Get(srv.URL + "/ai/uploads/" + u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(data, pngHeader) || resp.Header.Get("Content-Disposition") != "" {
+		t.Errorf("expect the image served inline, but got %q %q", data, resp.Header.Get("Content-Disposition"))
+	}
+
+	// 非图片只能下载
+	resp = postFile(t, srv.URL+"/ai/uploads", "x.html", []byte("<html><script>alert(1)</script></html>"))
+	json.NewDecoder(resp.Body).Decode(&u)
+	resp.Body.Close()
+	resp, err = http.Get(srv.URL + "/ai/uploads/" + u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Content-Disposition") == "" {
+		t.Errorf("expect html to be served as a download")
+	}
+
+	cases := []struct {
+		resp   func() *http.Response
+		expect int
+	}{
+		{func() *http.Response {
+			return postFile(t, srv.URL+"/ai/uploads", "big.bin", make([]byte, MaxUploadBytes+1))
+		}, http.StatusRequestEntityTooLarge},
+		{func() *http.Response {
+			resp, _ := http.Post(srv.URL+"/ai/uploads", "text/plain", bytes.NewReader([]byte("x")))
+			return resp
+		}, http.StatusBadRequest},
+		{func() *http.Response {
+			resp, _ := http.Get(srv.URL + "/ai/uploads/missing")
+			return resp
+		}, http.StatusNotFound},
+	}
+	for i, c := range cases {
+		resp := c.resp()
+		resp.Body.Close()
+		if resp.StatusCode != c.expect {
+			t.Errorf("case %d: expect %d, but got %d", i, c.expect, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrTokenBudgetExceeded is returned when a session has used up its token budget.
+var ErrTokenBudgetExceeded = errors.New("session token budget exceeded")
+
+// Usage counts the tokens consumed by model calls.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+func (u *Usage) add(v Usage) {
+	u.PromptTokens += v.PromptTokens
+	u.CompletionTokens += v.CompletionTokens
+	u.TotalTokens += v.TotalTokens
+}
+
+// turnUsage sums the usage reported on the model responses among msgs.
+func turnUsage(msgs []*schema.Message) Usage {
+	var u Usage
+	for _, msg := range msgs {
+		if msg.Role != schema.Assistant || msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+			continue
+		}
+		tu := msg.ResponseMeta.Usage
+		total := tu.TotalTokens
+		if total == 0 {
+			total = tu.PromptTokens + tu.CompletionTokens
+		}
+		u.add(Usage{PromptTokens: tu.PromptTokens, CompletionTokens: tu.CompletionTokens, TotalTokens: total})
+	}
+	return u
+}
+
+// TurnUsage reports the tokens of an agent's last turn together with the session
+// totals and budget.
+type TurnUsage struct {
+	Turn    Usage `json:"turn"`
+	Session Usage `json:"session"`
+	Budget  int   `json:"budget,omitempty"`
+}
+
+// UsageReporter is implemented by agents that account for their token usage.
+type UsageReporter interface {
+	LastUsage() TurnUsage
+}
+
+// WithTokenBudget caps the total tokens a session may use; turns are refused once it
+// is reached. A turn already running may overshoot it by its last model call. Zero
+// means the default set with SetTokenBudget, a negative value no budget.
+func WithTokenBudget(tokens int) Option {
+	return func(o *AgentOptions) {
+		o.TokenBudget = tokens
+	}
+}
+
+var (
+	usageMtx           sync.Mutex
+	defaultTokenBudget int
+	agentUsage         = make(map[AgentType]Usage)
+)
+
+// SetTokenBudget sets the per-session token budget of agents created without
+// WithTokenBudget; zero means unlimited.
+func SetTokenBudget(tokens int) {
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	defaultTokenBudget = tokens
+}
+
+func tokenBudget() int {
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	return defaultTokenBudget
+}
+
+// AgentUsage returns the tokens used by each agent type since the process started.
+func AgentUsage() map[AgentType]Usage {
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	usage := make(map[AgentType]Usage, len(agentUsage))
+	for t, u := range agentUsage {
+		usage[t] = u
+	}
+	return usage
+}
+
+func HandleUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"agents": AgentUsage()})
+}
+
+// usageMeter accounts for the tokens of one agent's session.
+type usageMeter struct {
+	agentType AgentType
+	budget    int
+
+	mtx     sync.Mutex
+	session Usage
+	last    Usage
+}
+
+func newUsageMeter(opts *AgentOptions, session Usage) *usageMeter {
+	return &usageMeter{agentType: opts.AgentType, budget: max(opts.TokenBudget, 0), session: session}
+}
+
+// record adds the usage of a finished turn.
+func (m *usageMeter) record(turn Usage) {
+	m.mtx.Lock()
+	m.last = turn
+	m.session.add(turn)
+	m.mtx.Unlock()
+
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	u := agentUsage[m.agentType]
+	u.add(turn)
+	agentUsage[m.agentType] = u
+}
+
+// checkBudget fails once the session, with spent tokens of the running turn, has
+// reached its budget.
+func (m *usageMeter) checkBudget(spent Usage) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.budget > 0 && m.session.TotalTokens+spent.TotalTokens >= m.budget {
+		return ErrTokenBudgetExceeded
+	}
+	return nil
+}
+
+// reset replaces the session totals with those just loaded from the store.
+func (m *usageMeter) reset(session Usage) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.session = session
+}
+
+func (m *usageMeter) sessionUsage() Usage {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.session
+}
+
+func (m *usageMeter) LastUsage() TurnUsage {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return TurnUsage{Turn: m.last, Session: m.session, Budget: m.budget}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+func withUsage(msg *schema.Message, prompt, completion int) *schema.Message {
+	msg.ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}}
+	return msg
+}
+
+func TestModelAgent_Usage(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore(0, 0)
+	m := &scriptedModel{responses: []*schema.Message{
+		withUsage(toolCalls("a"), 10, 5),
+		withUsage(schema.AssistantMessage("好的", nil), 20, 3),
+	}}
+	before := AgentUsage()["usage-test"].TotalTokens
+	a, err := newModelAgent("usage", ctx, m, &AgentOptions{
+		AgentType:   "usage-test",
+		Tools:       []tool.InvokableTool{sleepTool("a", 0)},
+		Store:       store,
+		TokenBudget: 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a tool round and the answer
+	if _, err := a.Chat(ctx, "你好"); err != nil {
+		t.Fatal(err)
+	}
+	if u := a.LastUsage(); u.Turn.TotalTokens != 38 || u.Turn.PromptTokens != 30 || u.Session.TotalTokens != 38 {
+		t.Errorf("expect 38 tokens for the turn, but got %+v", u)
+	}
+
+	// the streamed answer reports its usage on the last chunk
+	reader, err := a.ChatStream(ctx, "再说一次")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := reader.Recv(); errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	if u := a.LastUsage(); u.Turn.TotalTokens != 23 || u.Session.TotalTokens != 61 || u.Budget != 60 {
+		t.Errorf("expect 23 tokens for the turn and 61 for the session, but got %+v", u)
+	}
+	if sess, _ := store.Load("usage"); sess.Usage.TotalTokens != 61 {
+		t.Errorf("expect %d tokens stored with the session, but got %d", 61, sess.Usage.TotalTokens)
+	}
+	if n := AgentUsage()["usage-test"].TotalTokens - before; n != 61 {
+		t.Errorf("expect %d tokens for the agent type, but got %d", 61, n)
+	}
+
+	// the budget is used up
+	if _, err := a.Chat(ctx, "还有吗"); !errors.Is(err, ErrTokenBudgetExceeded) {
+		t.Errorf("expect %v, but got %v", ErrTokenBudgetExceeded, err)
+	}
+	b, _ := newModelAgent("usage", ctx, m, &AgentOptions{Store: store, TokenBudget: 60})
+	if _, err := b.ChatStream(ctx, "还有吗"); !errors.Is(err, ErrTokenBudgetExceeded) {
+		t.Errorf("expect %v after reload, but got %v", ErrTokenBudgetExceeded, err)
+	}
+}
+
+func TestNewAgent_TokenBudget(t *testing.T) {
+	SetTokenBudget(100)
+	defer SetTokenBudget(0)
+
+	for _, c := range []struct {
+		opts   []Option
+		expect int
+	}{
+		{nil, 100},
+		{[]Option{WithTokenBudget(0)}, 100},
+		{[]Option{WithTokenBudget(50)}, 50},
+		{[]Option{WithTokenBudget(-1)}, 0},
+	} {
+		opts := append(c.opts, WithSessionStore(NewMemorySessionStore(0, 0)))
+		agent, err := NewAgent(MockAgent, "budget", context.Background(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if budget := agent.(UsageReporter).LastUsage().Budget; budget != c.expect {
+			t.Errorf("expect budget %d, but got %d", c.expect, budget)
+		}
+	}
+}
+
+func TestToolLoop_CheckBudget(t *testing.T) {
+	loop := NewToolLoop(map[string]tool.InvokableTool{"a": sleepTool("a", 0)}, &AgentOptions{})
+	loop.CheckBudget = func(spent Usage) error {
+		if spent.TotalTokens >= 10 {
+			return ErrTokenBudgetExceeded
+		}
+		return nil
+	}
+	m := &scriptedModel{responses: []*schema.Message{withUsage(toolCalls("a"), 8, 4)}}
+	produced, err := loop.Generate(context.Background(), m, nil)
+	if !errors.Is(err, ErrTokenBudgetExceeded) || m.calls != 1 || len(produced) != 2 {
+		t.Errorf("expect budget to stop the loop after 1 call, but got %v after %d calls", err, m.calls)
+	}
+}
+
+func TestModelAgent_FailedTurnUsage(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore(0, 0)
+	// every turn asks for a tool and runs out of iterations after 300 tokens
+	m := &scriptedModel{responses: []*schema.Message{withUsage(toolCalls("a"), 200, 100)}}
+	opts := &AgentOptions{
+		Tools:         []tool.InvokableTool{sleepTool("a", 0)},
+		Store:         store,
+		TokenBudget:   500,
+		MaxIterations: 1,
+	}
+
+	a, _ := newModelAgent("failing", ctx, m, opts)
+	if _, err := a.Chat(ctx, "第一次"); !errors.Is(err, ErrMaxIterations) {
+		t.Fatalf("expect %v, but got %v", ErrMaxIterations, err)
+	}
+	sess, err := store.Load("failing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Usage.TotalTokens != 300 || len(sess.History) != 0 {
+		t.Errorf("expect the usage of the failed turn stored without its history, but got %d tokens and %d messages", sess.Usage.TotalTokens, len(sess.History))
+	}
+
+	b, _ := newModelAgent("failing", ctx, m, opts)
+	if _, err := b.ChatStream(ctx, "第二次"); !errors.Is(err, ErrMaxIterations) {
+		t.Fatalf("expect %v, but got %v", ErrMaxIterations, err)
+	}
+	if _, err := a.Chat(ctx, "第三次"); !errors.Is(err, ErrTokenBudgetExceeded) {
+		t.Errorf("expect %v once failed turns used up the budget, but got %v", ErrTokenBudgetExceeded, err)
+	}
+}
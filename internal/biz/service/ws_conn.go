@@ -0,0 +1,201 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Defaults for the zero fields of a WSConfig.
+const (
+	DefaultWSPingInterval    = 30 * time.Second
+	DefaultWSPongTimeout     = 60 * time.Second
+	DefaultWSWriteTimeout    = 10 * time.Second
+	DefaultWSSendQueue       = 256
+	DefaultWSMaxMessageBytes = 64 << 10
+)
+
+// WSConfig configures the WebSocket endpoint.
+type WSConfig struct {
+	// AllowedOrigins lists the browser origins that may connect: full origins such as
+	// "https://chat.example.com", host names (optionally "*.example.com"), or "*" for
+	// any. Empty means same-origin only. Clients sending no Origin are always accepted.
+	AllowedOrigins []string
+	// PingInterval is how often the server pings; a connection that has sent
+	// nothing, not even a pong, for PongTimeout is closed.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	// WriteTimeout bounds every write to the client.
+	WriteTimeout time.Duration
+	// SendQueue is the number of outgoing messages buffered per connection. A client
+	// too slow to keep it from filling up is disconnected.
+	SendQueue int
+	// MaxMessageBytes limits the size of a client message.
+	MaxMessageBytes int64
+}
+
+func (c WSConfig) withDefaults() WSConfig {
+	if c.PingInterval <= 0 {
+		c.PingInterval = DefaultWSPingInterval
+	}
+	if c.PongTimeout <= c.PingInterval {
+		c.PongTimeout = max(DefaultWSPongTimeout, 2*c.PingInterval)
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = DefaultWSWriteTimeout
+	}
+	if c.SendQueue <= 0 {
+		c.SendQueue = DefaultWSSendQueue
+	}
+	if c.MaxMessageBytes <= 0 {
+		c.MaxMessageBytes = DefaultWSMaxMessageBytes
+	}
+	return c
+}
+
+var (
+	wsConfigMtx sync.RWMutex
+	wsConfig    = WSConfig{}.withDefaults()
+)
+
+// SetWSConfig replaces the WebSocket settings used by new connections.
+func SetWSConfig(c WSConfig) {
+	wsConfigMtx.Lock()
+	defer wsConfigMtx.Unlock()
+	wsConfig = c.withDefaults()
+}
+
+func currentWSConfig() WSConfig {
+	wsConfigMtx.RLock()
+	defer wsConfigMtx.RUnlock()
+	return wsConfig
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: checkOrigin,
+}
+
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true // 非浏览器客户端不带 Origin
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	allowed := currentWSConfig().AllowedOrigins
+	if len(allowed) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+	var hosts []string
+	for _, a := range allowed {
+		switch {
+		case a == "*" || strings.EqualFold(a, origin):
+			return true
+		case !strings.Contains(a, "://"):
+			hosts = append(hosts, a)
+		}
+	}
+	return hostAllowed(hosts, strings.ToLower(u.Hostname()))
+}
+
+// send queues v for the writer. A full queue means the client is not keeping up,
+// so it is disconnected rather than stalling the turn.
+func (s *wsSession) send(v any) {
+	select {
+	case s.out <- v:
+	case <-s.ctx.Done():
+	default:
+		log.Printf("[WebSocket] send queue full, disconnecting slow client")
+		s.close(websocket.CloseTryAgainLater, "send queue full")
+	}
+}
+
+// close ends the connection with a close frame carrying code and reason. Only the
+// first call has an effect.
+func (s *wsSession) close(code int, reason string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closeCode != 0 {
+		return
+	}
+	s.closeCode, s.closeReason = code, reason
+	s.stop()
+}
+
+func (s *wsSession) closeStatus() (int, string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closeCode == 0 {
+		return websocket.CloseGoingAway, "server shutting down"
+	}
+	return s.closeCode, s.closeReason
+}
+
+// closeOnReadError picks the close code for the error that ended the read loop.
+func (s *wsSession) closeOnReadError(err error) {
+	var netErr net.Error
+	switch {
+	case websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived):
+		s.close(websocket.CloseNormalClosure, "")
+	case errors.Is(err, websocket.ErrReadLimit):
+		s.close(websocket.CloseMessageTooBig, "message too large")
+	case errors.As(err, &netErr) && netErr.Timeout():
+		s.close(websocket.CloseGoingAway, "heartbeat timeout")
+	default:
+		log.Printf("[WebSocket] read failed: %v", err)
+		s.close(websocket.CloseNormalClosure, "")
+	}
+}
+
+// writeLoop is the only goroutine writing to the connection: queued messages, pings
+// and finally the close frame.
+func (s *wsSession) writeLoop() {
+	defer close(s.writerDone)
+	defer s.conn.Close()
+	ticker := time.NewTicker(s.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case v := <-s.out:
+			s.conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+			if err := s.conn.WriteJSON(v); err != nil {
+				log.Printf("[WebSocket] write failed: %v", err)
+				s.close(websocket.CloseGoingAway, "write failed")
+				return
+			}
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.cfg.WriteTimeout)); err != nil {
+				log.Printf("[WebSocket] ping failed: %v", err)
+				s.close(websocket.CloseGoingAway, "ping failed")
+				return
+			}
+		case <-s.ctx.Done():
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush writes what is still queued and the close frame, all within one write
+// timeout.
+func (s *wsSession) flush() {
+	deadline := time.Now().Add(s.cfg.WriteTimeout)
+	s.conn.SetWriteDeadline(deadline)
+	for len(s.out) > 0 {
+		if err := s.conn.WriteJSON(<-s.out); err != nil {
+			return
+		}
+	}
+	code, reason := s.closeStatus()
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	defer SetWSConfig(WSConfig{})
+	cases := []struct {
+		allowed []string
+		origin  string
+		expect  bool
+	}{
+		{nil, "", true},
+		{nil, "http://example.com", true},
+		{nil, "http://evil.com", false},
+		{[]string{"https://chat.example.com"}, "https://chat.example.com", true},
+		{[]string{"https://chat.example.com"}, "http://chat.example.com", false},
+		{[]string{"*.example.com"}, "https://chat.example.com:8443", true},
+		{[]string{"*.example.com"}, "https://example.com.evil.com", false},
+		{[]string{"*"}, "http://anything.io", true},
+		{[]string{"example.com"}, "not a url", false},
+	}
+	for _, c := range cases {
+		SetWSConfig(WSConfig{AllowedOrigins: c.allowed})
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/ai/ws", nil)
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if got := checkOrigin(r); got != c.expect {
+			t.Errorf("%v %q: expect %v, but got %v", c.allowed, c.origin, c.expect, got)
+		}
+	}
+}
+
+func TestHandleWebSocket_Origin(t *testing.T) {
+	srv := newTestServer(t)
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(srv), http.Header{"Origin": {"http://evil.com"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expect %d for foreign origin, but got %v", http.StatusForbidden, err)
+	}
+}
+
+func TestHandleWebSocket_Heartbeat(t *testing.T) {
+	SetWSConfig(WSConfig{PingInterval: 20 * time.Millisecond, PongTimeout: 80 * time.Millisecond})
+	defer SetWSConfig(WSConfig{})
+	srv := newTestServer(t)
+
+	// a client that keeps reading answers the pings and stays connected
+	alive, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alive.Close()
+	events := make(chan wsEvent)
+	go func() {
+		defer close(events)
+		for {
+			var ev wsEvent
+			if err := alive.ReadJSON(&ev); err != nil {
+				return
+			}
+			events <- ev
+		}
+	}()
+
+	// a client that never reads misses them and is closed
+	silent, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	silent.SetPingHandler(func(string) error { return nil })
+
+	time.Sleep(300 * time.Millisecond)
+	silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	expectClose(t, silent, websocket.CloseGoingAway)
+
+	alive.WriteJSON(wsRequest{Type: "chat", SessionID: "ws-heartbeat", AgentType: string(MockAgent), Content: "还在吗"})
+	for ev := range events {
+		if ev.Event == "end" {
+			return
+		}
+	}
+	t.Errorf("expect the reading client to stay connected")
+}
+
+func TestHandleWebSocket_CloseCodes(t *testing.T) {
+	SetWSConfig(WSConfig{MaxMessageBytes: 64})
+	defer SetWSConfig(WSConfig{})
+	srv := newTestServer(t)
+
+	cases := []struct {
+		msg    string
+		expect int
+	}{
+		{"not json", websocket.CloseUnsupportedData},
+		{`{"content": "` + strings.Repeat("长", 64) + `"}`, websocket.CloseMessageTooBig},
+	}
+	for _, c := range cases {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		conn.WriteMessage(websocket.TextMessage, []byte(c.msg))
+		expectClose(t, conn, c.expect)
+		conn.Close()
+	}
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ai/ws"
+}
+
+func expectClose(t *testing.T, conn *websocket.Conn, code int) {
+	t.Helper()
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var ce *websocket.CloseError
+		if !errors.As(err, &ce) || ce.Code != code {
+			t.Errorf("expect close code %d, but got %v", code, err)
+		}
+		return
+	}
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// maxQueuedChats bounds the chat messages a client can send ahead while a turn is
+// in flight; further ones are rejected until the queue drains.
+const maxQueuedChats = 4
+
+// wsSession is the per-connection actor behind HandleWebSocket. One goroutine reads
+// the connection, dispatching cancel and approval messages immediately; chat
+// messages are queued and run one turn at a time, reusing the agent for as long as
+// the client stays on the same session, agent type and persona. Everything sent to
+// the client goes through the out queue to a single writer goroutine.
+type wsSession struct {
+	conn      *websocket.Conn
+	cfg       WSConfig
+	ctx       context.Context
+	stop      context.CancelFunc
+	locale    string
+	approvals *pendingApprovals
+	chats     chan wsRequest
+
+	out        chan any
+	writerDone chan struct{}
+
+	mtx         sync.Mutex
+	cancel      context.CancelFunc // cancels the turn in flight, nil when idle
+	closeCode   int
+	closeReason string
+
+	agent    Agent
+	agentKey string
+}
+
+func newWSSession(ctx context.Context, stop context.CancelFunc, conn *websocket.Conn, locale string, cfg WSConfig) *wsSession {
+	return &wsSession{
+		conn:       conn,
+		cfg:        cfg,
+		ctx:        ctx,
+		stop:       stop,
+		locale:     locale,
+		approvals:  newPendingApprovals(),
+		chats:      make(chan wsRequest, maxQueuedChats),
+		out:        make(chan any, cfg.SendQueue),
+		writerDone: make(chan struct{}),
+	}
+}
+
+// run serves the connection until it is closed by either side.
+func (s *wsSession) run() {
+	s.conn.SetReadLimit(s.cfg.MaxMessageBytes)
+	s.conn.SetReadDeadline(time.Now().Add(s.cfg.PongTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(s.cfg.PongTimeout))
+	})
+
+	go s.writeLoop()
+	go s.readLoop()
+	for req := range s.chats {
+		s.chat(req)
+	}
+	<-s.writerDone
+}
+
+func (s *wsSession) readLoop() {
+	defer close(s.chats)
+	defer s.approvals.close()
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			s.closeOnReadError(err)
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(s.cfg.PongTimeout))
+
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			log.Printf("error reading json: %v", err)
+			s.close(websocket.CloseUnsupportedData, "invalid message")
+			return
+		}
+		if req.SessionID == "" {
+			req.SessionID = "default"
+		}
+
+		switch req.Type {
+		case "approval":
+			if !s.approvals.resolve(req.ToolCallID, ApprovalDecision{Approved: req.Approved, Reason: req.Reason}) {
+				log.Printf("[WebSocket] no pending approval for tool call %s", req.ToolCallID)
+			}
+		case "cancel":
+			if !s.cancelTurn() {
+				log.Printf("[WebSocket] nothing to cancel for session %s", req.SessionID)
+			}
+		default:
+			busy := s.busy()
+			select {
+			case s.chats <- req:
+				if busy {
+					s.sendEvent(req.SessionID, AgentEvent{Event: EventQueued, Content: req.Content})
+				}
+			default:
+				s.sendEvent(req.SessionID, AgentEvent{Event: EventRejected, Content: req.Content, Error: "too many messages in flight"})
+			}
+		}
+	}
+}
+
+// busy reports whether a turn is running or waiting.
+func (s *wsSession) busy() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.cancel != nil || len(s.chats) > 0
+}
+
+// cancelTurn aborts the turn in flight, reporting whether there was one.
+func (s *wsSession) cancelTurn() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+func (s *wsSession) setCancel(cancel context.CancelFunc) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.cancel = cancel
+}
+
+func (s *wsSession) sendEvent(sessionID string, ev AgentEvent) {
+	ev.SessionID = sessionID
+	s.send(wsEvent{Type: "stringevent", AgentEvent: ev})
+}
+
+// agentFor returns the connection's agent for req, creating it on the first message
+// and whenever the client switches session, agent type or persona.
+func (s *wsSession) agentFor(req wsRequest) (Agent, error) {
+	agentType := req.AgentType
+	if agentType == "" {
+		agentType = string(DouBaoAgent)
+	}
+	key := agentType + "\x00" + req.SessionID + "\x00" + req.Persona
+	if s.agent != nil && s.agentKey == key {
+		return s.agent, nil
+	}
+
+	sessionID := req.SessionID
+	sendEvent := func(ev AgentEvent) {
+		s.sendEvent(sessionID, ev)
+	}
+	approver := func(ctx context.Context, ar ApprovalRequest) (ApprovalDecision, error) {
+		return s.approvals.ask(ctx, ar, func() {
+			sendEvent(AgentEvent{
+				Event:      EventApprovalRequest,
+				ToolCallID: ar.ToolCallID,
+				ToolName:   ar.ToolName,
+				Arguments:  ar.Arguments,
+			})
+		})
+	}
+	agent, err := NewAgent(AgentType(agentType), sessionID, s.ctx,
+		WithTools(DefaultToolRegistry().Tools()...),
+		WithEventHandler(sendEvent),
+		WithApprover(approver, 0),
+		WithPersona(req.Persona),
+		WithLocale(s.locale),
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.agent, s.agentKey = agent, key
+	return agent, nil
+}
+
+// chat runs one turn, streaming it back as stringevents. A turn that fails or is
+// cancelled discards the agent, so the next one starts again from the stored
+// session, which only ever holds completed turns.
+func (s *wsSession) chat(req wsRequest) {
+	if s.ctx.Err() != nil {
+		return // 连接已断开，丢弃排队的消息
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.setCancel(cancel)
+	defer func() {
+		s.setCancel(nil)
+		cancel()
+	}()
+
+	agent, err := s.agentFor(req)
+	if err != nil {
+		s.send(gin.H{"type": "error", "content": err.Error()})
+		return
+	}
+
+	err = s.stream(ctx, agent, req)
+	if err == nil {
+		return
+	}
+	s.agent = nil
+	if s.ctx.Err() != nil {
+		return
+	}
+	if ctx.Err() != nil {
+		s.sendEvent(req.SessionID, AgentEvent{Event: EventCancelled})
+	} else {
+		s.send(gin.H{"type": "error", "content": err.Error()})
+	}
+	s.send(gin.H{
+		"type":      "stringevent",
+		"event":     "end",
+		"sessionId": req.SessionID,
+	})
+}
+
+// stream sends the agent's answer chunk by chunk, followed by the end event once it
+// completes.
+func (s *wsSession) stream(ctx context.Context, agent Agent, req wsRequest) error {
+	callOpts, err := callTools(req.Tools)
+	if err != nil {
+		return err
+	}
+	msg, err := NewUserMessage(req.Content, req.Attachments)
+	if err != nil {
+		return err
+	}
+	reader, err := agent.ChatMessageStream(ctx, msg, callOpts...)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		chunk, err := reader.Recv()
+		if errors.Is(err, io.EOF) {
+			// Send end of stream event
+			s.send(addUsage(gin.H{
+				"type":      "stringevent",
+				"event":     "end",
+				"sessionId": req.SessionID,
+			}, agent))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if chunk.ReasoningContent != "" {
+			s.sendEvent(req.SessionID, AgentEvent{Event: EventThinking, Content: chunk.ReasoningContent})
+		}
+		if chunk.Content == "" {
+			continue
+		}
+		// Send chunk as "stringevent"
+		s.send(gin.H{
+			"type":      "stringevent",
+			"event":     "message",
+			"content":   chunk.Content,
+			"sessionId": req.SessionID,
+		})
+	}
+}
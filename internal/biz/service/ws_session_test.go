@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHandleWebSocket_Session(t *testing.T) {
+	var created atomic.Int32
+	script := &MockScript{
+		Replies: []MockReply{
+			{Content: "第一轮"},
+			{Content: "第二轮"},
+			{Content: strings.Repeat("很长的回答", 100)},
+		},
+		ChunkSize:  1,
+		ChunkDelay: 5 * time.Millisecond,
+	}
+	RegisterAgent("scripted-session", AgentFactory{
+		New: func(sessionId string, ctx context.Context, opts *AgentOptions) (Agent, error) {
+			created.Add(1)
+			opts.Mock = script
+			return NewMock(sessionId, ctx, opts)
+		},
+	})
+	srv := newTestServer(t)
+	sessionID := fmt.Sprintf("ws-session-%d", time.Now().UnixNano())
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	chat := wsRequest{Type: "chat", SessionID: sessionID, AgentType: "scripted-session", Content: "你好"}
+	readUntil := func(event string) []wsEvent {
+		var events []wsEvent
+		for {
+			var ev wsEvent
+			if err := conn.ReadJSON(&ev); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, ev)
+			if ev.Event == event {
+				return events
+			}
+		}
+	}
+
+	// two complete turns share one agent
+	for range 2 {
+		conn.WriteJSON(chat)
+		readUntil("end")
+	}
+	if n := created.Load(); n != 1 {
+		t.Errorf("expect %d agent, but got %d", 1, n)
+	}
+
+	// messages sent during generation are queued until the queue is full
+	conn.WriteJSON(chat)
+	readUntil("message")
+	for range maxQueuedChats + 1 {
+		conn.WriteJSON(chat)
+	}
+	var queued, rejected int
+	for queued+rejected < maxQueuedChats+1 {
+		var ev wsEvent
+		if err := conn.ReadJSON(&ev); err != nil {
+			t.Fatal(err)
+		}
+		switch ev.Event {
+		case EventQueued:
+			queued++
+		case EventRejected:
+			rejected++
+		case "end":
+			t.Fatalf("expect turn still running, but got end")
+		}
+	}
+	if queued != maxQueuedChats || rejected != 1 {
+		t.Errorf("expect %d queued and 1 rejected, but got %d and %d", maxQueuedChats, queued, rejected)
+	}
+
+	// cancel stops the turn in flight and the next queued message starts
+	conn.WriteJSON(wsRequest{Type: "cancel"})
+	events := readUntil("end")
+	if ev := events[len(events)-2]; ev.Event != EventCancelled {
+		t.Errorf("expect %s before end, but got %s", EventCancelled, ev.Event)
+	}
+	readUntil("message")
+	if n := len(mustLoad(t, sessionID).History); n != 4 {
+		t.Errorf("expect cancelled turn left out of history, but got %d messages", n)
+	}
+}
@@ -0,0 +1,121 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config mirrors configs/config.yaml.
+type Config struct {
+	Personas  map[string]Persona `yaml:"personas"`
+	Tools     []Tool             `yaml:"tools"`
+	WebSocket WebSocket          `yaml:"websocket"`
+	Knowledge Knowledge          `yaml:"knowledge"`
+	// TokenBudget caps the tokens each session may use; zero is unlimited.
+	TokenBudget int `yaml:"token_budget"`
+}
+
+// WebSocket configures the /ai/ws endpoint; zero values fall back to the defaults
+// of the service package.
+type WebSocket struct {
+	AllowedOrigins  []string      `yaml:"allowed_origins"`
+	PingInterval    time.Duration `yaml:"ping_interval"`
+	PongTimeout     time.Duration `yaml:"pong_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	SendQueue       int           `yaml:"send_queue"`
+	MaxMessageBytes int64         `yaml:"max_message_bytes"`
+}
+
+// Knowledge configures the local knowledge base searched by the search_knowledge
+// tool; it is disabled when Dir is empty. Zero values fall back to the defaults of
+// the knowledge package.
+type Knowledge struct {
+	Dir          string `yaml:"dir"`
+	ChunkSize    int    `yaml:"chunk_size"`
+	ChunkOverlap int    `yaml:"chunk_overlap"`
+	Dimensions   int    `yaml:"dimensions"`
+}
+
+// Tool declares a tool offered to the agents. Type selects how it is built:
+//   - http: calls URL, a Go template over the arguments declared by Params (or by
+//     Parameters, a raw JSON schema); arguments are validated before the request.
+//     Method (GET, POST or PUT) and Body, also a template, shape the request;
+//     header values may reference environment variables as ${VAR}. Extract is an
+//     optional JMESPath expression applied to JSON responses.
+//     Requests are limited to allowed_hosts (default: the host of URL) and
+//     allowed_schemes, and never reach private addresses unless
+//     allow_private_networks is set.
+//   - sql: read-only queries against the SQLite database at DSN, together with a
+//     <name>_schema companion tool.
+//   - builtin: a tool implemented in code, looked up by Name.
+//
+// Any tool can add a fallback result, retries with backoff, a result cache and a
+// circuit breaker, and can require the user's approval before each call.
+type Tool struct {
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type"`
+	Description string            `yaml:"description"`
+	URL         string            `yaml:"url"`
+	Method      string            `yaml:"method"`
+	Body        string            `yaml:"body"`
+	Extract     string            `yaml:"extract"`
+	Params      []ToolParam       `yaml:"params"`
+	Parameters  map[string]any    `yaml:"parameters"`
+	Headers     map[string]string `yaml:"headers"`
+
+	AllowedHosts         []string `yaml:"allowed_hosts"`
+	AllowedSchemes       []string `yaml:"allowed_schemes"`
+	AllowPrivateNetworks bool     `yaml:"allow_private_networks"`
+	MaxResponseBytes     int64    `yaml:"max_response_bytes"`
+
+	DSN     string `yaml:"dsn"`
+	MaxRows int    `yaml:"max_rows"`
+
+	Fallback         string        `yaml:"fallback"`
+	Retries          int           `yaml:"retries"`
+	RetryBackoff     time.Duration `yaml:"retry_backoff"`
+	CacheTTL         time.Duration `yaml:"cache_ttl"`
+	CircuitThreshold int           `yaml:"circuit_threshold"`
+	CircuitCooldown  time.Duration `yaml:"circuit_cooldown"`
+
+	RequiresApproval bool `yaml:"requires_approval"`
+}
+
+// ToolParam declares one typed argument of an http tool.
+type ToolParam struct {
+	Name        string   `yaml:"name"`
+	Type        string   `yaml:"type"`
+	Description string   `yaml:"description"`
+	Required    bool     `yaml:"required"`
+	Minimum     *float64 `yaml:"minimum"`
+	Maximum     *float64 `yaml:"maximum"`
+	Enum        []any    `yaml:"enum"`
+	Default     any      `yaml:"default"`
+}
+
+// Persona is a named system prompt. SystemPrompt is a Go template that can use
+// {{.session_id}}, {{.date}} and {{.locale}}.
+type Persona struct {
+	Description  string `yaml:"description"`
+	SystemPrompt string `yaml:"system_prompt"`
+}
+
+// Load reads the config at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,28 @@
+package conf
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load("../../configs/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Personas["default"].SystemPrompt == "" {
+		t.Errorf("expect a default persona")
+	}
+	if len(cfg.Tools) == 0 || cfg.Tools[0].Name == "" || cfg.Tools[0].Type == "" {
+		t.Errorf("expect declared tools, but got %v", cfg.Tools)
+	}
+	if cfg.WebSocket.PingInterval != 30*time.Second {
+		t.Errorf("expect %v, but got %v", 30*time.Second, cfg.WebSocket.PingInterval)
+	}
+
+	cfg, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || len(cfg.Personas) != 0 {
+		t.Errorf("expect empty config for missing file, but got %v, %v", cfg, err)
+	}
+}
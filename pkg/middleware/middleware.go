@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"log"
 	"runtime/debug"
 )
 
@@ -55,13 +56,15 @@ func (m *Manager[I, O]) Run(ctx context.Context, req I, finalHandler Handler[I,
 	return m.Build(finalHandler)(ctx, req)
 }
 
-// Recovery creates a middleware that recovers from panics.
+// Recovery creates a middleware that recovers from panics. The stack trace is
+// logged; the returned error only carries the panic value.
 func Recovery[I, O any]() Middleware[I, O] {
 	return func(next Handler[I, O]) Handler[I, O] {
 		return func(ctx context.Context, req I) (res O, err error) {
 			defer func() {
 				if r := recover(); r != nil {
-					err = fmt.Errorf("panic recovered: %v\n%s", r, string(debug.Stack()))
+					log.Printf("[Recovery] panic recovered: %v\n%s", r, debug.Stack())
+					err = fmt.Errorf("panic recovered: %v", r)
 				}
 			}()
 			return next(ctx, req)
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while a circuit breaker rejects calls.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Fallback creates a middleware that replaces an error from next with the result of
+// fallback. shouldFallback decides which errors are replaced; nil means all of them.
+func Fallback[I, O any](fallback Handler[I, O], shouldFallback func(error) bool) Middleware[I, O] {
+	return func(next Handler[I, O]) Handler[I, O] {
+		return func(ctx context.Context, req I) (O, error) {
+			res, err := next(ctx, req)
+			if err == nil || (shouldFallback != nil && !shouldFallback(err)) {
+				return res, err
+			}
+			return fallback(ctx, req)
+		}
+	}
+}
+
+// RetryOptions configures Retry.
+type RetryOptions struct {
+	// Attempts is the total number of calls, including the first.
+	Attempts int
+	// Backoff is the delay before the first retry; it doubles after each retry up to
+	// MaxBackoff, if set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable decides which errors are retried; nil means all of them.
+	Retryable func(error) bool
+}
+
+// Retry creates a middleware that calls next again with exponential backoff until it
+// succeeds, returns a non-retryable error, runs out of attempts or ctx is done.
+func Retry[I, O any](opts RetryOptions) Middleware[I, O] {
+	return func(next Handler[I, O]) Handler[I, O] {
+		return func(ctx context.Context, req I) (res O, err error) {
+			backoff := opts.Backoff
+			for attempt := 1; ; attempt++ {
+				res, err = next(ctx, req)
+				if err == nil || attempt >= opts.Attempts || (opts.Retryable != nil && !opts.Retryable(err)) {
+					return res, err
+				}
+				select {
+				case <-ctx.Done():
+					return res, err
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if opts.MaxBackoff > 0 && backoff > opts.MaxBackoff {
+					backoff = opts.MaxBackoff
+				}
+			}
+		}
+	}
+}
+
+// CircuitBreaker opens after Threshold consecutive failures and rejects calls for
+// Cooldown. After that a single trial call is let through: success closes the
+// circuit again, failure reopens it.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mtx      sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// Allow reports whether a call may proceed.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	if cb.failures < cb.Threshold {
+		return true
+	}
+	if cb.trial || time.Since(cb.openedAt) < cb.Cooldown {
+		return false
+	}
+	cb.trial = true
+	return true
+}
+
+// Record reports the outcome of an allowed call.
+func (cb *CircuitBreaker) Record(failed bool) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	cb.trial = false
+	if !failed {
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.failures >= cb.Threshold {
+		cb.openedAt = time.Now()
+	}
+}
+
+// Skip ends an allowed call whose outcome says nothing about the guarded service,
+// leaving the failure count as it is.
+func (cb *CircuitBreaker) Skip() {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	cb.trial = false
+}
+
+// CircuitBreak creates a middleware that guards next with cb. isFailure decides
+// which errors count against the circuit; nil means all of them. Other errors
+// neither count nor reset it.
+func CircuitBreak[I, O any](cb *CircuitBreaker, isFailure func(error) bool) Middleware[I, O] {
+	return func(next Handler[I, O]) Handler[I, O] {
+		return func(ctx context.Context, req I) (O, error) {
+			if !cb.Allow() {
+				var zero O
+				return zero, ErrCircuitOpen
+			}
+			res, err := next(ctx, req)
+			if err != nil && isFailure != nil && !isFailure(err) {
+				cb.Skip()
+			} else {
+				cb.Record(err != nil)
+			}
+			return res, err
+		}
+	}
+}
+
+// maxCacheEntries bounds a Cache; expired entries are purged when it is reached.
+const maxCacheEntries = 1024
+
+type cacheEntry[O any] struct {
+	res     O
+	expires time.Time
+}
+
+// Cache creates a middleware that remembers successful results for ttl. key maps a
+// request to its cache key; requests it reports as uncacheable always call next.
+func Cache[I, O any](ttl time.Duration, key func(I) (string, bool)) Middleware[I, O] {
+	var mtx sync.Mutex
+	entries := make(map[string]cacheEntry[O])
+	return func(next Handler[I, O]) Handler[I, O] {
+		return func(ctx context.Context, req I) (O, error) {
+			k, ok := key(req)
+			if !ok {
+				return next(ctx, req)
+			}
+			mtx.Lock()
+			e, hit := entries[k]
+			mtx.Unlock()
+			if hit && time.Now().Before(e.expires) {
+				return e.res, nil
+			}
+
+			res, err := next(ctx, req)
+			if err != nil {
+				return res, err
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			if len(entries) >= maxCacheEntries {
+				now := time.Now()
+				for k, e := range entries {
+					if now.After(e.expires) {
+						delete(entries, k)
+					}
+				}
+				if len(entries) >= maxCacheEntries {
+					entries = make(map[string]cacheEntry[O])
+				}
+			}
+			entries[k] = cacheEntry[O]{res: res, expires: time.Now().Add(ttl)}
+			return res, nil
+		}
+	}
+}
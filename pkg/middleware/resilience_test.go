@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errBoom = errors.New("boom")
+
+// flaky fails the first n calls.
+func flaky(n int, calls *int) Handler[string, string] {
+	return func(ctx context.Context, req string) (string, error) {
+		*calls++
+		if *calls <= n {
+			return "", errBoom
+		}
+		return "ok:" + req, nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	h := Retry[string, string](RetryOptions{Attempts: 3, Backoff: time.Millisecond})(flaky(2, &calls))
+	if res, err := h(context.Background(), "a"); err != nil || res != "ok:a" || calls != 3 {
+		t.Errorf("expect success on 3rd attempt, but got %v, %v after %d calls", res, err, calls)
+	}
+
+	calls = 0
+	h = Retry[string, string](RetryOptions{Attempts: 3, Backoff: time.Millisecond})(flaky(5, &calls))
+	if _, err := h(context.Background(), "a"); !errors.Is(err, errBoom) || calls != 3 {
+		t.Errorf("expect %d calls, but got %d (%v)", 3, calls, err)
+	}
+
+	calls = 0
+	noRetry := func(err error) bool { return false }
+	h = Retry[string, string](RetryOptions{Attempts: 3, Retryable: noRetry})(flaky(5, &calls))
+	if h(context.Background(), "a"); calls != 1 {
+		t.Errorf("expect %d calls, but got %d", 1, calls)
+	}
+}
+
+func TestCircuitBreak(t *testing.T) {
+	calls := 0
+	cb := NewCircuitBreaker(2, 20*time.Millisecond)
+	h := CircuitBreak[string, string](cb, nil)(flaky(3, &calls))
+
+	h(context.Background(), "a")
+	h(context.Background(), "a")
+	if _, err := h(context.Background(), "a"); !errors.Is(err, ErrCircuitOpen) || calls != 2 {
+		t.Errorf("expect open circuit after %d calls, but got %v after %d", 2, err, calls)
+	}
+
+	// after the cooldown one trial goes through; it fails and the circuit reopens
+	time.Sleep(30 * time.Millisecond)
+	if _, err := h(context.Background(), "a"); !errors.Is(err, errBoom) {
+		t.Errorf("expect trial call, but got %v", err)
+	}
+	if _, err := h(context.Background(), "a"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expect reopened circuit, but got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if res, err := h(context.Background(), "a"); err != nil || res != "ok:a" {
+		t.Errorf("expect successful trial, but got %v, %v", res, err)
+	}
+	if _, err := h(context.Background(), "a"); err != nil {
+		t.Errorf("expect closed circuit, but got %v", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	calls := 0
+	key := func(req string) (string, bool) { return req, req != "nocache" }
+	h := Cache[string, string](20*time.Millisecond, key)(flaky(0, &calls))
+
+	h(context.Background(), "a")
+	h(context.Background(), "a")
+	h(context.Background(), "nocache")
+	h(context.Background(), "nocache")
+	if calls != 3 {
+		t.Errorf("expect %d calls, but got %d", 3, calls)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if h(context.Background(), "a"); calls != 4 {
+		t.Errorf("expect expired entry to call through, but got %d calls", calls)
+	}
+
+	// errors are not cached
+	calls = 0
+	h = Cache[string, string](time.Minute, key)(flaky(1, &calls))
+	h(context.Background(), "a")
+	if res, err := h(context.Background(), "a"); err != nil || res != "ok:a" {
+		t.Errorf("expect retry after error, but got %v, %v", res, err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	calls := 0
+	fallback := func(ctx context.Context, req string) (string, error) { return "fallback", nil }
+	h := Fallback[string, string](fallback, nil)(flaky(1, &calls))
+	if res, _ := h(context.Background(), "a"); res != "fallback" {
+		t.Errorf("expect %s, but got %s", "fallback", res)
+	}
+	if res, _ := h(context.Background(), "a"); res != "ok:a" {
+		t.Errorf("expect %s, but got %s", "ok:a", res)
+	}
+
+	calls = 0
+	h = Fallback[string, string](fallback, func(err error) bool { return false })(flaky(1, &calls))
+	if _, err := h(context.Background(), "a"); !errors.Is(err, errBoom) {
+		t.Errorf("expect original error, but got %v", err)
+	}
+}